@@ -2,6 +2,16 @@ module github.com/artie-labs/ducktape/api
 
 go 1.24.0
 
-require golang.org/x/net v0.46.0
+require (
+	go.opentelemetry.io/otel v1.38.0
+	golang.org/x/net v0.46.0
+)
 
-require golang.org/x/text v0.30.0 // indirect
+require (
+	github.com/go-logr/logr v1.4.3 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	go.opentelemetry.io/auto/sdk v1.1.0 // indirect
+	go.opentelemetry.io/otel/metric v1.38.0 // indirect
+	go.opentelemetry.io/otel/trace v1.38.0 // indirect
+	golang.org/x/text v0.30.0 // indirect
+)
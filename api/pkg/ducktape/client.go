@@ -4,12 +4,17 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"iter"
 	"net"
 	"net/http"
+	"net/url"
+	"strings"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 	"golang.org/x/net/http2"
 )
 
@@ -18,14 +23,128 @@ type Client struct {
 	httpClient *http.Client
 }
 
-func NewClient(baseURL string) *Client {
-	tr := &http2.Transport{
-		AllowHTTP: true,
-		DialTLSContext: func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
-			var d net.Dialer
-			return d.DialContext(ctx, network, addr)
-		},
+// ClientOption configures a [Client].
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	credentials func(req *http.Request) error
+	tlsConfig   *tls.Config
+}
+
+// WithTLSConfig sets the TLS configuration used to connect to an https:// base URL.
+func WithTLSConfig(config *tls.Config) ClientOption {
+	return func(o *clientOptions) {
+		o.tlsConfig = config.Clone()
+	}
+}
+
+// WithRootCAs makes the client verify the server certificate against the given CAs instead of the system ones.
+func WithRootCAs(pool *x509.CertPool) ClientOption {
+	return func(o *clientOptions) {
+		o.tls().RootCAs = pool
+	}
+}
+
+// WithClientCertificate makes the client present a certificate to servers that verify client certificates (mTLS).
+func WithClientCertificate(certificate tls.Certificate) ClientOption {
+	return func(o *clientOptions) {
+		o.tls().Certificates = []tls.Certificate{certificate}
+	}
+}
+
+func (o *clientOptions) tls() *tls.Config {
+	if o.tlsConfig == nil {
+		o.tlsConfig = &tls.Config{}
+	}
+	return o.tlsConfig
+}
+
+// WithAPIKey makes the client authenticate every request with a static API key.
+func WithAPIKey(key string) ClientOption {
+	return func(o *clientOptions) {
+		o.credentials = func(req *http.Request) error {
+			req.Header.Set(APIKeyHeader, key)
+			return nil
+		}
+	}
+}
+
+// WithBearerToken makes the client authenticate every request with a bearer token, e.g. a JWT.
+func WithBearerToken(token string) ClientOption {
+	return WithTokenSource(func(context.Context) (string, error) { return token, nil })
+}
+
+// WithTokenSource makes the client authenticate every request with the bearer token returned by the function, which
+// is called for each request so it can refresh short-lived tokens.
+func WithTokenSource(token func(ctx context.Context) (string, error)) ClientOption {
+	return func(o *clientOptions) {
+		o.credentials = func(req *http.Request) error {
+			value, err := token(req.Context())
+			if err != nil {
+				return fmt.Errorf("failed to get a bearer token: %w", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+value)
+			return nil
+		}
+	}
+}
+
+// credentialsTransport adds the credentials of the client to every request.
+type credentialsTransport struct {
+	base        http.RoundTripper
+	credentials func(req *http.Request) error
+}
+
+func (t *credentialsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request it was given
+	req = req.Clone(req.Context())
+	if err := t.credentials(req); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// traceContextTransport propagates the trace context of every request, e.g. the W3C traceparent header, through the
+// global OpenTelemetry propagator set with [otel.SetTextMapPropagator].
+type traceContextTransport struct {
+	base http.RoundTripper
+}
+
+func (t *traceContextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request it was given
+	req = req.Clone(req.Context())
+	otel.GetTextMapPropagator().Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+	return t.base.RoundTrip(req)
+}
+
+// NewClient returns a client for the server at baseURL. http:// URLs are served over cleartext HTTP/2 (h2c), and https://
+// URLs over TLS, see [WithTLSConfig], [WithRootCAs] and [WithClientCertificate]. The trace context of the request
+// contexts is sent along through the global OpenTelemetry propagator, see [otel.SetTextMapPropagator].
+func NewClient(baseURL string, opts ...ClientOption) *Client {
+	var o clientOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var tr http.RoundTripper
+	if strings.HasPrefix(strings.ToLower(baseURL), "https://") {
+		tr = &http2.Transport{TLSClientConfig: o.tlsConfig}
+	} else {
+		tr = &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr)
+			},
+		}
 	}
+	if o.credentials != nil {
+		tr = &credentialsTransport{base: tr, credentials: o.credentials}
+	}
+	tr = &traceContextTransport{base: tr}
 	return &Client{baseURL: baseURL, httpClient: &http.Client{Transport: tr}}
 }
 
@@ -60,6 +179,17 @@ func (c *Client) Execute(
 	connectionString string,
 	marshalFunc func(r ExecuteRequest) ([]byte, error),
 	unmarshalFunc func(r []byte) (*ExecuteResponse, error),
+) (*ExecuteResponse, error) {
+	return c.execute(ctx, request, connectionString, "", marshalFunc, unmarshalFunc)
+}
+
+func (c *Client) execute(
+	ctx context.Context,
+	request ExecuteRequest,
+	connectionString string,
+	transactionID string,
+	marshalFunc func(r ExecuteRequest) ([]byte, error),
+	unmarshalFunc func(r []byte) (*ExecuteResponse, error),
 ) (*ExecuteResponse, error) {
 	url := fmt.Sprintf("%s%s", c.baseURL, ExecuteRoute)
 	body, err := marshalFunc(request)
@@ -74,6 +204,10 @@ func (c *Client) Execute(
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set(DuckDBConnectionStringHeader, connectionString)
+	if transactionID != "" {
+		req.Header.Set(DuckDBTransactionIDHeader, transactionID)
+	}
+	setContextHeaders(req)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -94,6 +228,17 @@ func (c *Client) Query(
 	connectionString string,
 	marshalFunc func(r QueryRequest) ([]byte, error),
 	unmarshalFunc func(r []byte) (*QueryResponse, error),
+) (*QueryResponse, error) {
+	return c.query(ctx, request, connectionString, "", marshalFunc, unmarshalFunc)
+}
+
+func (c *Client) query(
+	ctx context.Context,
+	request QueryRequest,
+	connectionString string,
+	transactionID string,
+	marshalFunc func(r QueryRequest) ([]byte, error),
+	unmarshalFunc func(r []byte) (*QueryResponse, error),
 ) (*QueryResponse, error) {
 	url := fmt.Sprintf("%s%s", c.baseURL, QueryRoute)
 	body, err := marshalFunc(request)
@@ -108,6 +253,45 @@ func (c *Client) Query(
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set(DuckDBConnectionStringHeader, connectionString)
+	if transactionID != "" {
+		req.Header.Set(DuckDBTransactionIDHeader, transactionID)
+	}
+	setContextHeaders(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalFunc(responseBody)
+}
+
+func (c *Client) Explain(
+	ctx context.Context,
+	request ExplainRequest,
+	connectionString string,
+	marshalFunc func(r ExplainRequest) ([]byte, error),
+	unmarshalFunc func(r []byte) (*ExplainResponse, error),
+) (*ExplainResponse, error) {
+	url := fmt.Sprintf("%s%s", c.baseURL, ExplainRoute)
+	body, err := marshalFunc(request)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(DuckDBConnectionStringHeader, connectionString)
+	setContextHeaders(req)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -143,6 +327,7 @@ func (c *Client) Append(
 	req.Header.Set(DuckDBDatabaseHeader, database)
 	req.Header.Set(DuckDBSchemaHeader, schema)
 	req.Header.Set(DuckDBTableHeader, table)
+	setContextHeaders(req)
 
 	pr, pw := io.Pipe()
 
@@ -180,3 +365,73 @@ func (c *Client) Append(
 	}
 	return unmarshalFunc(responseBody)
 }
+
+func (c *Client) ListOperations(
+	ctx context.Context,
+	unmarshalFunc func(r []byte) (*ListOperationsResponse, error),
+) (*ListOperationsResponse, error) {
+	url := fmt.Sprintf("%s%s", c.baseURL, OperationsRoute)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalFunc(responseBody)
+}
+
+func (c *Client) CancelOperation(
+	ctx context.Context,
+	operationID string,
+	unmarshalFunc func(r []byte) (*CancelOperationResponse, error),
+) (*CancelOperationResponse, error) {
+	route := strings.Replace(CancelOperationRoute, "{id}", url.PathEscape(operationID), 1)
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s%s", c.baseURL, route), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalFunc(responseBody)
+}
+
+func (c *Client) StatementCacheStats(
+	ctx context.Context,
+	unmarshalFunc func(r []byte) (*StatementCacheStatsResponse, error),
+) (*StatementCacheStatsResponse, error) {
+	url := fmt.Sprintf("%s%s", c.baseURL, StatementCacheRoute)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalFunc(responseBody)
+}
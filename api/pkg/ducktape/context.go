@@ -0,0 +1,53 @@
+package ducktape
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+type idempotencyKeyContextKey struct{}
+
+type timeoutContextKey struct{}
+
+type cacheTTLContextKey struct{}
+
+type readOnlyContextKey struct{}
+
+// WithIdempotencyKey returns a context that makes [Client.Execute] and [Client.Append] send the given idempotency key.
+// Retrying a request with the same key returns the original response instead of applying the write twice.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey{}, key)
+}
+
+// WithTimeout returns a context that asks the server to interrupt the query, execute or append after the given duration.
+// The server may enforce a shorter limit, in which case that one wins.
+func WithTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, timeoutContextKey{}, timeout)
+}
+
+// WithCacheTTL returns a context that lets the server cache the response of [Client.Query] for the given duration.
+// Cached responses are discarded as soon as an execute or append writes to the same database through the server.
+func WithCacheTTL(ctx context.Context, ttl time.Duration) context.Context {
+	return context.WithValue(ctx, cacheTTLContextKey{}, ttl)
+}
+
+// WithReadOnly returns a context that asks the server to reject anything but SELECT statements, including appends.
+func WithReadOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readOnlyContextKey{}, true)
+}
+
+func setContextHeaders(req *http.Request) {
+	if key, ok := req.Context().Value(idempotencyKeyContextKey{}).(string); ok && key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	if timeout, ok := req.Context().Value(timeoutContextKey{}).(time.Duration); ok && timeout > 0 {
+		req.Header.Set(DuckDBTimeoutHeader, timeout.String())
+	}
+	if ttl, ok := req.Context().Value(cacheTTLContextKey{}).(time.Duration); ok && ttl > 0 {
+		req.Header.Set(DuckDBCacheTTLHeader, ttl.String())
+	}
+	if readOnly, ok := req.Context().Value(readOnlyContextKey{}).(bool); ok && readOnly {
+		req.Header.Set(DuckDBReadOnlyHeader, "true")
+	}
+}
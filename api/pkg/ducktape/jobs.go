@@ -0,0 +1,133 @@
+package ducktape
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SubmitQuery runs the query as a background job and returns as soon as it is accepted.
+// Use [Client.WaitForJob] to wait for it to finish and [Client.JobResults] to read the rows.
+// A timeout set with [WithTimeout] bounds how long the job may run.
+func (c *Client) SubmitQuery(
+	ctx context.Context,
+	request QueryRequest,
+	connectionString string,
+	marshalFunc func(r QueryRequest) ([]byte, error),
+	unmarshalFunc func(r []byte) (*JobResponse, error),
+) (*JobResponse, error) {
+	body, err := marshalFunc(request)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s%s", c.baseURL, JobsRoute), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(DuckDBConnectionStringHeader, connectionString)
+	setContextHeaders(req)
+	return c.doJobRequest(req, unmarshalFunc)
+}
+
+func (c *Client) GetJob(
+	ctx context.Context,
+	jobID string,
+	unmarshalFunc func(r []byte) (*JobResponse, error),
+) (*JobResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.jobURL(JobRoute, jobID), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.doJobRequest(req, unmarshalFunc)
+}
+
+func (c *Client) CancelJob(
+	ctx context.Context,
+	jobID string,
+	unmarshalFunc func(r []byte) (*JobResponse, error),
+) (*JobResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.jobURL(CancelJobRoute, jobID), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.doJobRequest(req, unmarshalFunc)
+}
+
+// WaitForJob polls the job every pollInterval until it has finished, the server returns an error or ctx is done.
+// The returned job may have failed or been cancelled, check its state.
+func (c *Client) WaitForJob(
+	ctx context.Context,
+	jobID string,
+	pollInterval time.Duration,
+	unmarshalFunc func(r []byte) (*JobResponse, error),
+) (*JobResponse, error) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		response, err := c.GetJob(ctx, jobID, unmarshalFunc)
+		if err != nil {
+			return nil, err
+		}
+		if response.Error != nil || response.Job == nil || response.Job.State.Finished() {
+			return response, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// JobResults streams the results of a job that has succeeded, the caller must close the returned reader.
+// A [JobResultFormatJSON] body decodes into a [QueryResponse].
+func (c *Client) JobResults(
+	ctx context.Context,
+	jobID string,
+	format JobResultFormat,
+) (io.ReadCloser, error) {
+	route := c.jobURL(JobResultsRoute, jobID) + "?" + url.Values{"format": {string(format)}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, "GET", route, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to fetch the job results: %s: %s", resp.Status, body)
+	}
+	return resp.Body, nil
+}
+
+func (c *Client) jobURL(route string, jobID string) string {
+	return fmt.Sprintf("%s%s", c.baseURL, strings.Replace(route, "{id}", url.PathEscape(jobID), 1))
+}
+
+func (c *Client) doJobRequest(req *http.Request, unmarshalFunc func(r []byte) (*JobResponse, error)) (*JobResponse, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalFunc(responseBody)
+}
@@ -0,0 +1,108 @@
+package ducktape
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Tx is a server-side transaction started with [Client.BeginTx].
+// The server rolls the transaction back if it stays idle for too long, so callers must always Commit or Rollback.
+type Tx struct {
+	client           *Client
+	connectionString string
+	id               string
+}
+
+func (c *Client) BeginTx(
+	ctx context.Context,
+	connectionString string,
+	unmarshalFunc func(r []byte) (*BeginTransactionResponse, error),
+) (*Tx, error) {
+	url := fmt.Sprintf("%s%s", c.baseURL, BeginTransactionRoute)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set(DuckDBConnectionStringHeader, connectionString)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := unmarshalFunc(responseBody)
+	if err != nil {
+		return nil, err
+	}
+	if response.Error != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %s", *response.Error)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to begin transaction: %s", resp.Status)
+	}
+
+	return &Tx{client: c, connectionString: connectionString, id: response.TransactionID}, nil
+}
+
+func (tx *Tx) ID() string {
+	return tx.id
+}
+
+func (tx *Tx) Execute(
+	ctx context.Context,
+	request ExecuteRequest,
+	marshalFunc func(r ExecuteRequest) ([]byte, error),
+	unmarshalFunc func(r []byte) (*ExecuteResponse, error),
+) (*ExecuteResponse, error) {
+	return tx.client.execute(ctx, request, tx.connectionString, tx.id, marshalFunc, unmarshalFunc)
+}
+
+func (tx *Tx) Query(
+	ctx context.Context,
+	request QueryRequest,
+	marshalFunc func(r QueryRequest) ([]byte, error),
+	unmarshalFunc func(r []byte) (*QueryResponse, error),
+) (*QueryResponse, error) {
+	return tx.client.query(ctx, request, tx.connectionString, tx.id, marshalFunc, unmarshalFunc)
+}
+
+func (tx *Tx) Commit(ctx context.Context) error {
+	return tx.end(ctx, CommitTransactionRoute)
+}
+
+func (tx *Tx) Rollback(ctx context.Context) error {
+	return tx.end(ctx, RollbackTransactionRoute)
+}
+
+func (tx *Tx) end(ctx context.Context, route string) error {
+	url := fmt.Sprintf("%s%s", tx.client.baseURL, route)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set(DuckDBConnectionStringHeader, tx.connectionString)
+	req.Header.Set(DuckDBTransactionIDHeader, tx.id)
+
+	resp, err := tx.client.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		responseBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to end transaction %q: %s: %s", tx.id, resp.Status, responseBody)
+	}
+
+	return nil
+}
@@ -1,39 +1,152 @@
 package ducktape
 
+import (
+	"encoding/json"
+	"time"
+)
+
 const (
 	ExecuteRoute = "/api/execute"
 	QueryRoute   = "/api/query"
 	AppendRoute  = "/api/append"
 	PingRoute    = "/api/ping"
+	ExplainRoute = "/api/explain"
+
+	BeginTransactionRoute    = "/api/tx/begin"
+	CommitTransactionRoute   = "/api/tx/commit"
+	RollbackTransactionRoute = "/api/tx/rollback"
+
+	OperationsRoute      = "/api/admin/operations"
+	CancelOperationRoute = "/api/admin/operations/{id}/cancel"
+	StatementCacheRoute  = "/api/admin/statement-cache"
+
+	JobsRoute       = "/api/jobs"
+	JobRoute        = "/api/jobs/{id}"
+	JobResultsRoute = "/api/jobs/{id}/results"
+	CancelJobRoute  = "/api/jobs/{id}/cancel"
+
+	LivenessRoute  = "/health/live"
+	ReadinessRoute = "/health/ready"
 
 	DuckDBConnectionStringHeader = "X-DuckDB-Connection-String"
 	DuckDBDatabaseHeader         = "X-DuckDB-Database"
 	DuckDBSchemaHeader           = "X-DuckDB-Schema"
 	DuckDBTableHeader            = "X-DuckDB-Table"
+	DuckDBTransactionIDHeader    = "X-DuckDB-Transaction-ID"
+	DuckDBTimeoutHeader          = "X-DuckDB-Timeout"
+	DuckDBOperationIDHeader      = "X-DuckDB-Operation-ID"
+	DuckDBCacheTTLHeader         = "X-DuckDB-Cache-TTL"
+	DuckDBCacheHeader            = "X-DuckDB-Cache"
+	DuckDBReadOnlyHeader         = "X-DuckDB-Read-Only"
+	IdempotencyKeyHeader         = "Idempotency-Key"
+	IdempotentReplayedHeader     = "Idempotent-Replayed"
+	APIKeyHeader                 = "X-API-Key"
+	RequestIDHeader              = "X-Request-ID"
 )
 
+// ErrorResponse is returned by requests that fail before reaching an endpoint, e.g. with 401 Unauthorized. Its shape
+// matches the Error field of every other response, so their unmarshal functions decode it too.
+type ErrorResponse struct {
+	Error *string `json:"error"`
+}
+
+// TypedArg is an argument bound with an explicit DuckDB type, e.g. {"type": "DECIMAL(38,10)", "value": "1.5"}.
+// The value is sent as text and cast to the type by DuckDB, so it keeps its full precision.
+// Use it in Args or NamedArgs wherever a plain JSON value would be ambiguous.
+type TypedArg struct {
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
+
 type QueryRequest struct {
 	Query string `json:"query"`
 	Args  []any  `json:"args"`
+	// NamedArgs binds $name placeholders by name, it cannot be combined with Args.
+	NamedArgs map[string]any `json:"namedArgs,omitempty"`
+	// DryRun validates the query without running it, see [StatementValidation].
+	DryRun bool `json:"dryRun,omitempty"`
+	// PageSize returns at most this many rows along with a cursor to fetch the rest, instead of buffering every row.
+	PageSize int `json:"pageSize,omitempty"`
+	// Cursor fetches the next page of a paginated query, Query and Args are ignored.
+	Cursor string `json:"cursor,omitempty"`
 }
 
 type QueryResponse struct {
-	Rows  []map[string]any `json:"rows"`
-	Error *string          `json:"error"`
+	Rows []map[string]any `json:"rows"`
+	// Truncated is set when the server's row or byte limit cut the result short.
+	Truncated bool `json:"truncated,omitempty"`
+	// NextCursor is set when a paginated query has more rows, pass it as [QueryRequest.Cursor] to fetch them.
+	NextCursor *string               `json:"nextCursor,omitempty"`
+	Validation []StatementValidation `json:"validation,omitempty"`
+	Error      *string               `json:"error"`
+}
+
+type ExplainRequest struct {
+	Query string `json:"query"`
+	Args  []any  `json:"args"`
+	// NamedArgs binds $name placeholders by name, it cannot be combined with Args.
+	NamedArgs map[string]any `json:"namedArgs,omitempty"`
+	// Analyze runs the query and returns the per-operator profile (timings, cardinalities) instead of the estimated plan.
+	// The query is executed inside a transaction that is always rolled back.
+	Analyze bool `json:"analyze,omitempty"`
+}
+
+type ExplainResponse struct {
+	// Plan is DuckDB's JSON query plan, or its JSON profiling output when the request set Analyze.
+	Plan  json.RawMessage `json:"plan"`
+	Error *string         `json:"error"`
 }
 
 type ExecuteStatement struct {
 	Query string `json:"query"`
 	Args  []any  `json:"args"`
+	// NamedArgs binds $name placeholders by name, it cannot be combined with Args.
+	NamedArgs map[string]any `json:"namedArgs,omitempty"`
+	// Optional statements that fail are skipped instead of rolling back the whole batch.
+	Optional bool `json:"optional,omitempty"`
 }
 
+type ErrorMode string
+
+const (
+	// ErrorModeAbort rolls back the batch when any non-optional statement fails.
+	ErrorModeAbort ErrorMode = "abort"
+	// ErrorModeContinue treats every statement in the batch as optional.
+	ErrorModeContinue ErrorMode = "continue"
+)
+
 type ExecuteRequest struct {
 	Statements []ExecuteStatement `json:"statements"`
+	ErrorMode  ErrorMode          `json:"errorMode,omitempty"`
+	// DryRun validates every statement without executing or committing anything, see [StatementValidation].
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+type SkippedStatement struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
 }
 
 type ExecuteResponse struct {
-	RowsAffectedCount int64   `json:"rowsAffected"`
-	Error             *string `json:"error"`
+	RowsAffectedCount int64                 `json:"rowsAffected"`
+	SkippedStatements []SkippedStatement    `json:"skippedStatements,omitempty"`
+	Validation        []StatementValidation `json:"validation,omitempty"`
+	Error             *string               `json:"error"`
+}
+
+type ColumnDescription struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// StatementValidation is the outcome of parsing and binding a statement in dry-run mode.
+type StatementValidation struct {
+	Index          int                 `json:"index"`
+	Valid          bool                `json:"valid"`
+	StatementType  string              `json:"statementType,omitempty"`
+	ParameterCount int                 `json:"parameterCount"`
+	Columns        []ColumnDescription `json:"columns,omitempty"`
+	Error          *string             `json:"error,omitempty"`
 }
 
 func (r ExecuteResponse) LastInsertId() (int64, error) {
@@ -57,3 +170,130 @@ type AppendResponse struct {
 	RowsAppended int64   `json:"rowsAppended"`
 	Error        *string `json:"error"`
 }
+
+type BeginTransactionResponse struct {
+	TransactionID string  `json:"transactionId"`
+	Error         *string `json:"error"`
+}
+
+type TransactionResponse struct {
+	Error *string `json:"error"`
+}
+
+// Operation is an in-flight query, execute or append request.
+type Operation struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+	DSN  string `json:"dsn"`
+	// SQL is the query text, statements of an execute request are separated by semicolons.
+	// For appends it is the qualified name of the target table.
+	SQL           string    `json:"sql,omitempty"`
+	ClientAddress string    `json:"clientAddress"`
+	StartedAt     time.Time `json:"startedAt"`
+	// Rows counts the rows returned, affected or appended so far.
+	Rows int64 `json:"rows"`
+}
+
+type ListOperationsResponse struct {
+	Operations []Operation `json:"operations"`
+	Error      *string     `json:"error"`
+}
+
+type CancelOperationResponse struct {
+	Error *string `json:"error"`
+}
+
+// StatementCacheStats describes the server's prepared statement cache, counters are cumulative since startup.
+type StatementCacheStats struct {
+	Capacity  int     `json:"capacity"`
+	Size      int     `json:"size"`
+	Hits      int64   `json:"hits"`
+	Misses    int64   `json:"misses"`
+	Evictions int64   `json:"evictions"`
+	HitRate   float64 `json:"hitRate"`
+}
+
+type StatementCacheStatsResponse struct {
+	Stats StatementCacheStats `json:"stats"`
+	Error *string             `json:"error"`
+}
+
+type HealthStatus string
+
+const (
+	HealthStatusOK   HealthStatus = "ok"
+	HealthStatusFail HealthStatus = "fail"
+)
+
+// HealthCheck is the outcome of one of the checks behind [ReadinessRoute], e.g. "dsn:analytics" or "disk:/data".
+type HealthCheck struct {
+	Name   string       `json:"name"`
+	Status HealthStatus `json:"status"`
+	// Detail describes what was checked, e.g. the free space of a directory.
+	Detail string  `json:"detail,omitempty"`
+	Error  *string `json:"error"`
+}
+
+// HealthResponse is returned by [LivenessRoute] and [ReadinessRoute], the status fails as soon as one check does.
+type HealthResponse struct {
+	Status HealthStatus  `json:"status"`
+	Checks []HealthCheck `json:"checks"`
+}
+
+type JobState string
+
+const (
+	JobStateRunning   JobState = "running"
+	JobStateSucceeded JobState = "succeeded"
+	JobStateFailed    JobState = "failed"
+	JobStateCancelled JobState = "cancelled"
+)
+
+// Finished reports whether the job has stopped running.
+func (s JobState) Finished() bool {
+	return s != JobStateRunning
+}
+
+// JobResultFormat is the encoding of the results returned by [JobResultsRoute], selected with the format query parameter.
+type JobResultFormat string
+
+const (
+	// JobResultFormatJSON returns a [QueryResponse] object.
+	JobResultFormatJSON JobResultFormat = "json"
+	// JobResultFormatNDJSON returns one JSON object per row.
+	JobResultFormatNDJSON JobResultFormat = "ndjson"
+	// JobResultFormatParquet returns the results as a Parquet file.
+	JobResultFormatParquet JobResultFormat = "parquet"
+)
+
+// Job is a query that runs in the background, its results are kept until ExpiresAt.
+type Job struct {
+	ID          string     `json:"id"`
+	DSN         string     `json:"dsn"`
+	SQL         string     `json:"sql"`
+	State       JobState   `json:"state"`
+	SubmittedAt time.Time  `json:"submittedAt"`
+	FinishedAt  *time.Time `json:"finishedAt,omitempty"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	// Rows is the number of result rows, it is set once the job has succeeded.
+	Rows int64 `json:"rows"`
+	// ResultBytes is the size of the stored Parquet results, it is set once the job has succeeded.
+	ResultBytes int64 `json:"resultBytes"`
+	// Error is set when the job failed or was cancelled.
+	Error *string `json:"error,omitempty"`
+	// Progress is set while the job is running, it shows that the job is still working but not how much is left.
+	Progress *JobProgress `json:"progress,omitempty"`
+}
+
+// JobProgress describes the work a running job has done so far. It holds no row count or percentage: the driver does
+// not expose DuckDB's query progress, and the number of rows is only known once the query has finished. DuckDB writes
+// the Parquet results while the query runs, so BytesWritten grows until the job has finished.
+type JobProgress struct {
+	ElapsedSeconds float64 `json:"elapsedSeconds"`
+	BytesWritten   int64   `json:"bytesWritten"`
+}
+
+type JobResponse struct {
+	Job   *Job    `json:"job,omitempty"`
+	Error *string `json:"error"`
+}
@@ -1,35 +1,43 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
 	"log"
 	"log/slog"
 	"net/http"
 	"os"
-	"strings"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/artie-labs/ducktape/internal/api"
+	"github.com/artie-labs/ducktape/internal/config"
 	"github.com/artie-labs/ducktape/internal/logging"
-	"golang.org/x/net/http2"
-	"golang.org/x/net/http2/h2c"
+	"github.com/artie-labs/ducktape/internal/tracing"
 )
 
 func main() {
-	var level slog.Level
-	logLevelEnv := os.Getenv("DUCKTAPE_LOG")
-
-	switch strings.ToLower(logLevelEnv) {
-	case "debug", "d":
-		level = slog.LevelDebug
-	case "info", "i":
-		level = slog.LevelInfo
-	case "warn", "w", "warning":
-		level = slog.LevelWarn
-	case "error", "e":
-		level = slog.LevelError
-	default:
-		level = slog.LevelInfo
+	loaded, err := config.Load(os.Args[1:], os.LookupEnv, os.Stderr)
+	if errors.Is(err, config.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Failed to load the configuration: %v", err)
+	}
+	cfg := loaded.Config
+	if loaded.PrintConfig {
+		if err := config.Print(os.Stdout, cfg); err != nil {
+			log.Fatalf("Failed to print the configuration: %v", err)
+		}
+		return
 	}
 
+	// Validated by config.Load
+	level, _ := cfg.LogLevel()
+
 	infoHandler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelDebug, // Don't filter here, we'll filter in the custom handler
 	})
@@ -44,20 +52,166 @@ func main() {
 		ErrorHandler: errorHandler,
 	})
 	slog.SetDefault(logger)
+	if loaded.File != "" {
+		slog.Info("loaded the configuration", slog.String("file", loaded.File))
+	}
 
-	mux := http.NewServeMux()
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		ServiceName: cfg.Tracing.ServiceName,
+		SampleRatio: cfg.Tracing.SampleRatio,
+		Output:      os.Stdout,
+	})
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
 
-	api.RegisterApiRoutes(mux)
-	api.RegisterHealthCheckRoutes(mux)
+	var policy *api.Policy
+	if cfg.Auth.PolicyFile != "" {
+		if policy, err = api.LoadPolicy(cfg.Auth.PolicyFile); err != nil {
+			log.Fatalf("Failed to load the policy file: %v", err)
+		}
+	}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	var allowedDSNOptions []string
+	if cfg.DSN.AllowedOptions != nil {
+		// Set but empty allows no option at all
+		allowedDSNOptions = append([]string{}, *cfg.DSN.AllowedOptions...)
 	}
 
-	// Wrap the mux with h2c to support both HTTP/1.1 and HTTP/2
-	h2cHandler := h2c.NewHandler(mux, &http2.Server{})
+	api.Configure(api.Options{
+		MaxQueryDuration:   time.Duration(cfg.Limits.MaxQueryDuration),
+		MaxResultRows:      cfg.Limits.MaxResultRows,
+		MaxResultBytes:     cfg.Limits.MaxResultBytes,
+		MaxBodyBytes:       cfg.Limits.MaxBodyBytes,
+		RouteMaxBodyBytes:  cfg.Limits.RouteMaxBodyBytes,
+		JobDir:             cfg.Jobs.Dir,
+		JobResultTTL:       time.Duration(cfg.Jobs.ResultTTL),
+		MaxJobDuration:     time.Duration(cfg.Jobs.MaxDuration),
+		StatementCacheSize: cfg.Cache.StatementCacheSize,
+		ResultCacheBytes:   cfg.Cache.ResultCacheBytes,
+		ReadOnly:           cfg.DSN.ReadOnly,
+		ReadOnlyDSNs:       cfg.DSN.ReadOnlyDSNs,
+		DataDir:            cfg.DSN.DataDir,
+		AllowInMemory:      cfg.DSN.AllowInMemory,
+		AllowedDSNOptions:  allowedDSNOptions,
+		DeniedDSNOptions:   cfg.DSN.DeniedOptions,
+		DSNAliases:         cfg.DSN.Aliases,
+		DSNDefaults:        cfg.DuckDB.Defaults,
+		HealthCheckDSNs:    cfg.Health.DSNs,
+		HealthMinFreeBytes: cfg.Health.MinFreeBytes,
+		HealthCheckTimeout: time.Duration(cfg.Health.CheckTimeout),
+		Policy:             policy,
+	})
 
-	log.Printf("Starting server on port %s\n", port)
-	log.Fatal(http.ListenAndServe("0.0.0.0:"+port, h2cHandler))
+	var authenticators []api.Authenticator
+	if len(cfg.Auth.APIKeys) > 0 {
+		// Configured as name=key, looked up by key
+		keys := make(map[string]string, len(cfg.Auth.APIKeys))
+		for name, key := range cfg.Auth.APIKeys {
+			keys[key] = name
+		}
+		authenticators = append(authenticators, api.NewAPIKeyAuthenticator(keys))
+	}
+	if len(cfg.Auth.JWTKeys) > 0 {
+		keys := make(map[string][]byte, len(cfg.Auth.JWTKeys))
+		for keyID, secret := range cfg.Auth.JWTKeys {
+			// Validated by config.Load
+			keys[keyID], _ = base64.StdEncoding.DecodeString(secret)
+		}
+		authenticators = append(authenticators, api.NewJWTAuthenticator(keys, cfg.Auth.JWTIssuer, cfg.Auth.JWTAudience))
+	}
+
+	var tlsConfig *tls.Config
+	if cfg.TLS.CertFile != "" {
+		certificates, err := api.NewCertReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			log.Fatalf("Failed to load the TLS certificate: %v", err)
+		}
+		go certificates.Watch(context.Background(), time.Duration(cfg.TLS.ReloadInterval))
+
+		if tlsConfig, err = api.NewTLSConfig(certificates, cfg.TLS.ClientCAFile, cfg.TLS.RequireClientCert); err != nil {
+			log.Fatalf("Failed to configure TLS: %v", err)
+		}
+		if cfg.TLS.ClientCAFile != "" {
+			// Tried last, so that API keys and tokens take precedence over the certificate of the client
+			authenticators = append(authenticators, api.ClientCertAuthenticator{})
+		}
+	}
+
+	if len(authenticators) == 0 {
+		slog.Warn("no API keys, JWT keys or client CA are configured, the API is served without authentication")
+	}
+
+	apiMux := http.NewServeMux()
+	api.RegisterApiRoutes(apiMux)
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/", api.Authenticate(apiMux, authenticators...))
+	api.RegisterHealthCheckRoutes(mux, authenticators...)
+	api.RegisterMetricsRoutes(mux, authenticators...)
+
+	// HTTP/2 is negotiated through ALPN over TLS, and served with prior knowledge (h2c) otherwise
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+	server := &http.Server{
+		Addr:              cfg.Listen,
+		Handler:           api.LogRequests(mux),
+		TLSConfig:         tlsConfig,
+		Protocols:         &protocols,
+		ReadHeaderTimeout: time.Duration(cfg.Server.ReadHeaderTimeout),
+		ReadTimeout:       time.Duration(cfg.Server.ReadTimeout),
+		WriteTimeout:      time.Duration(cfg.Server.WriteTimeout),
+		IdleTimeout:       time.Duration(cfg.Server.IdleTimeout),
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+		HTTP2: &http.HTTP2Config{
+			MaxConcurrentStreams: cfg.Server.HTTP2MaxConcurrentStreams,
+			WriteByteTimeout:     time.Duration(cfg.Server.HTTP2WriteByteTimeout),
+		},
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	serveErr := make(chan error, 1)
+	go func() {
+		if tlsConfig != nil {
+			log.Printf("Starting TLS server on %s\n", cfg.Listen)
+			serveErr <- server.ListenAndServeTLS("", "")
+		} else {
+			log.Printf("Starting server on %s\n", cfg.Listen)
+			serveErr <- server.ListenAndServe()
+		}
+	}()
+	select {
+	case err := <-serveErr:
+		log.Fatal(err)
+	case <-ctx.Done():
+	}
+	// A second signal kills the process right away
+	stop()
+
+	drainTimeout := time.Duration(cfg.Shutdown.DrainTimeout)
+	slog.Info("shutting down", slog.Duration("drainDelay", time.Duration(cfg.Shutdown.DrainDelay)), slog.Duration("drainTimeout", drainTimeout))
+	// Readiness fails from now on, requests are still served until the load balancers have noticed
+	api.Drain()
+	time.Sleep(time.Duration(cfg.Shutdown.DrainDelay))
+	drainCtx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+	if err := server.Shutdown(drainCtx); err != nil {
+		slog.Warn("in-flight requests did not finish in time", slog.Any("error", err))
+	}
+	if err := api.Shutdown(drainCtx); err != nil {
+		slog.Error("failed to shut down cleanly", slog.Any("error", err))
+	}
+	server.Close()
+	// The drain timeout may have run out already, the remaining spans get their own deadline
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Warn("failed to export the remaining spans", slog.Any("error", err))
+	}
+	slog.Info("shut down")
 }
@@ -15,6 +15,7 @@ import (
 	"github.com/artie-labs/ducktape/api/pkg/ducktape"
 	"github.com/artie-labs/ducktape/internal/utils"
 	"github.com/duckdb/duckdb-go/v2"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
@@ -57,12 +58,29 @@ func handleAppend(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx := r.Context()
+	ctx, cancel, err := withQueryTimeout(r)
+	if err != nil {
+		errMsg := err.Error()
+		handleBadRequestJSON(w, ducktape.AppendResponse{Error: &errMsg}, err)
+		return
+	}
+	defer cancel()
 
-	rowsAppended, bytesRead, err := Append(ctx, dsn, database, schema, table, r.Body)
+	ctx, err = withReadOnlyHeader(ctx, r)
 	if err != nil {
 		errMsg := err.Error()
-		handleInternalServerErrorJSON(w, ducktape.AppendResponse{Error: &errMsg}, err)
+		handleBadRequestJSON(w, ducktape.AppendResponse{Error: &errMsg}, err)
+		return
+	}
+
+	ctx, operation := startOperation(ctx, w, r, "append", dsn, fmt.Sprintf("%s.%s.%s", database, schema, table))
+	defer operations.finish(operation)
+
+	rowsAppended, bytesRead, replayed, err := AppendIdempotent(ctx, dsn, r.Header.Get(ducktape.IdempotencyKeyHeader), database, schema, table, r.Body)
+	if err != nil {
+		err = contextError(ctx, err)
+		errMsg := err.Error()
+		handleErrorJSON(w, ducktape.AppendResponse{Error: &errMsg}, err)
 		return
 	}
 
@@ -78,29 +96,103 @@ func handleAppend(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
+	if replayed {
+		w.Header().Set(ducktape.IdempotentReplayedHeader, "true")
+	}
 	w.WriteHeader(http.StatusOK)
 	w.Write(body)
-	slog.Info(fmt.Sprintf("append complete for table %s.%s.%s", database, schema, table), slog.Int64("totalRowsAppended", rowsAppended), slog.Uint64("totalBytesRead", bytesRead), slog.Duration("elapsed", time.Since(start)))
+	LoggerFromContext(r.Context()).Info(fmt.Sprintf("append complete for table %s.%s.%s", database, schema, table), slog.Int64("totalRowsAppended", rowsAppended), slog.Uint64("totalBytesRead", bytesRead), slog.Duration("elapsed", time.Since(start)))
 }
 
 func Append(ctx context.Context, dsn string, database string, schema string, table string, input io.Reader) (rowsAppended int64, bytesRead uint64, err error) {
-	db, err := sql.Open("duckdb", dsn)
-	if err != nil {
-		return 0, 0, fmt.Errorf("failed to start a SQL client for append(%q): %w", "duckdb", err)
+	rowsAppended, bytesRead, _, err = AppendIdempotent(ctx, dsn, "", database, schema, table, input)
+	return rowsAppended, bytesRead, err
+}
+
+// AppendIdempotent streams rows like [Append]. When idempotencyKey is set, the rows and the response are committed in a
+// single transaction, and later calls with the same key return the stored response without reading the input.
+func AppendIdempotent(ctx context.Context, dsn string, idempotencyKey string, database string, schema string, table string, input io.Reader) (rowsAppended int64, bytesRead uint64, replayed bool, err error) {
+	if isReadOnly(ctx, dsn) {
+		return 0, 0, false, fmt.Errorf("%w: appends cannot run", ErrReadOnly)
 	}
-	defer db.Close()
+	// Runs last, once the rows have been committed or rolled back
+	defer results.invalidate(dsn)
 
-	if err = db.Ping(); err != nil {
-		return 0, 0, fmt.Errorf("failed to validate the DB connection for append(%q): %w", "duckdb", err)
+	db, err := openDB(ctx, dsn, "append")
+	if err != nil {
+		return 0, 0, false, err
 	}
+	defer db.Close()
 
 	conn, err := db.Conn(ctx)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to get a connection for append(%q): %w", "duckdb", err)
+		return 0, 0, false, fmt.Errorf("failed to get a connection for append(%q): %w", "duckdb", err)
 	}
 	defer conn.Close()
 
-	columnMetadata, err := utils.GetColumnMetadata(ctx, conn, database, schema, table)
+	idempotencyKeyTable := qualifiedIdempotencyTable(database)
+	if idempotencyKey != "" {
+		if _, err := conn.ExecContext(ctx, "BEGIN TRANSACTION"); err != nil {
+			return 0, 0, false, fmt.Errorf("failed to begin a transaction for append(%q): %w", "duckdb", err)
+		}
+		committed := false
+		defer func() {
+			if !committed {
+				conn.ExecContext(context.Background(), "ROLLBACK")
+			}
+		}()
+
+		// The rows are streamed, so the request is hashed while it is read
+		h := newRequestHash(ducktape.AppendRoute)
+		fmt.Fprintf(h, "%s.%s.%s\x00", database, schema, table)
+
+		stored, storedHash, found, err := lookupIdempotencyKey(ctx, conn, idempotencyKeyTable, ducktape.AppendRoute, idempotencyKey)
+		if err != nil {
+			return 0, 0, false, err
+		}
+		if found {
+			n, err := io.Copy(h, input)
+			if err != nil {
+				return 0, 0, false, fmt.Errorf("failed to read the request body: %w", err)
+			}
+			bytesRead = uint64(n)
+			if err := checkRequestHash(storedHash, requestHashString(h)); err != nil {
+				return 0, bytesRead, false, err
+			}
+			var response ducktape.AppendResponse
+			if err := json.Unmarshal(stored, &response); err != nil {
+				return 0, 0, false, fmt.Errorf("failed to unmarshal the stored response: %w", err)
+			}
+			return response.RowsAppended, bytesRead, true, nil
+		}
+
+		rowsAppended, bytesRead, err = appendRows(ctx, conn, database, schema, table, io.TeeReader(input, h))
+		if err != nil {
+			return 0, 0, false, err
+		}
+
+		stored, err = json.Marshal(ducktape.AppendResponse{RowsAppended: rowsAppended})
+		if err != nil {
+			return 0, 0, false, fmt.Errorf("failed to marshal the response: %w", err)
+		}
+		if err := storeIdempotencyKey(ctx, conn, idempotencyKeyTable, ducktape.AppendRoute, idempotencyKey, requestHashString(h), stored); err != nil {
+			return 0, 0, false, err
+		}
+		if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+			return 0, 0, false, fmt.Errorf("failed to commit the transaction: %w", idempotencyConflict(err))
+		}
+		committed = true
+		return rowsAppended, bytesRead, false, nil
+	}
+
+	rowsAppended, bytesRead, err = appendRows(ctx, conn, database, schema, table, input)
+	return rowsAppended, bytesRead, false, err
+}
+
+func appendRows(ctx context.Context, conn *sql.Conn, database string, schema string, table string, input io.Reader) (rowsAppended int64, bytesRead uint64, err error) {
+	metadataCtx, span := startSpan(ctx, "duckdb.column_metadata", attribute.String("db.collection.name", fmt.Sprintf("%s.%s.%s", database, schema, table)))
+	columnMetadata, err := utils.GetColumnMetadata(metadataCtx, conn, database, schema, table)
+	endSpan(span, err)
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to get column metadata for append(%q): %w", "duckdb", err)
 	}
@@ -118,12 +210,24 @@ func Append(ctx context.Context, dsn string, database string, schema string, tab
 		return 0, 0, fmt.Errorf("failed to create an appender(%q): %w", "duckdb", err)
 	}
 	defer appender.Close()
+	flush := func() error {
+		_, span := startSpan(ctx, "duckdb.appender.flush")
+		start := time.Now()
+		err := appender.Flush()
+		appenderFlushDuration.Observe(time.Since(start).Seconds())
+		endSpan(span, err)
+		return err
+	}
 
 	// Stream NDJSON from request body
 	scanner := bufio.NewScanner(input)
 	var bytesSinceFlush uint64
 
 	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return 0, 0, fmt.Errorf("append was interrupted: %w", err)
+		}
+
 		line := scanner.Bytes()
 		if len(line) == 0 {
 			continue // Skip empty lines
@@ -132,9 +236,14 @@ func Append(ctx context.Context, dsn string, database string, schema string, tab
 		lineBytes := uint64(len(line))
 		bytesRead += lineBytes
 		bytesSinceFlush += lineBytes
+		appendBytesTotal.Add(float64(lineBytes))
 
 		var rowMsg ducktape.RowMessage
 		if err := json.Unmarshal(line, &rowMsg); err != nil {
+			if readErr := scanner.Err(); readErr != nil {
+				// The scanner hands out the partial line read before failing, e.g. at the request body limit
+				return 0, 0, fmt.Errorf("failed to read request stream: %w", readErr)
+			}
 			return 0, 0, fmt.Errorf("failed to unmarshal row message: %w", err)
 		}
 
@@ -155,11 +264,13 @@ func Append(ctx context.Context, dsn string, database string, schema string, tab
 		}
 
 		rowsAppended++
+		operationFromContext(ctx).addRows(1)
+		rowsAppendedTotal.Inc()
 
 		// Flush if we've reached row limit OR bytes limit
 		if rowsAppended%flushInterval == 0 || bytesSinceFlush >= flushBytesLimit {
-			slog.Info("flushing appender", slog.Int64("rowsAppended", rowsAppended), slog.Uint64("bytesRead", bytesRead), slog.Uint64("bytesSinceFlush", bytesSinceFlush))
-			if err := appender.Flush(); err != nil {
+			LoggerFromContext(ctx).Info("flushing appender", slog.Int64("rowsAppended", rowsAppended), slog.Uint64("bytesRead", bytesRead), slog.Uint64("bytesSinceFlush", bytesSinceFlush))
+			if err := flush(); err != nil {
 				return 0, 0, fmt.Errorf("failed to flush appender: %w", err)
 			}
 			bytesSinceFlush = 0 // Reset counter after flush
@@ -170,7 +281,7 @@ func Append(ctx context.Context, dsn string, database string, schema string, tab
 		return 0, 0, fmt.Errorf("failed to read request stream: %w", err)
 	}
 
-	if err := appender.Flush(); err != nil {
+	if err := flush(); err != nil {
 		return 0, 0, fmt.Errorf("failed to flush appender: %w", err)
 	}
 
@@ -0,0 +1,220 @@
+package api
+
+import (
+	"database/sql"
+	stdjson "encoding/json"
+	"fmt"
+	"maps"
+	"math/big"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// typeNamePattern matches the DuckDB types an argument can be annotated with: a (possibly multi-word) type name with
+// optional width and scale, optionally followed by list or array suffixes. It keeps the annotation from injecting SQL.
+var typeNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_ ]*(\(\s*\d+\s*(,\s*\d+\s*)?\))?(\[\d*\])*$`)
+
+// bindArgs returns the statement to run and the values to bind to it: the positional args, or the named args as
+// [sql.NamedArg] for statements that use $name placeholders. DuckDB cannot mix both styles in one statement.
+//
+// Numbers decoded with UseNumber are bound as exact integers when they have no fraction. An argument of the form
+// {"type": "DECIMAL(38,10)", "value": "..."} is bound as text and its placeholder is wrapped in a cast to that type, so
+// DuckDB parses the value exactly instead of going through a float or comparing it as VARCHAR.
+func bindArgs(query string, args []any, namedArgs map[string]any) (string, []any, error) {
+	if len(args) > 0 && len(namedArgs) > 0 {
+		return "", nil, fmt.Errorf("%w: args and namedArgs cannot be combined", ErrInvalidRequest)
+	}
+
+	// Placeholder types are keyed by position (starting at 1) for positional args and by name for named args
+	types := make(map[string]string)
+	bound := make([]any, 0, len(args)+len(namedArgs))
+	for i, arg := range args {
+		value, typeName, err := bindArg(arg)
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: failed to bind arg %d: %w", ErrInvalidRequest, i, err)
+		}
+		if typeName != "" {
+			types[strconv.Itoa(i+1)] = typeName
+		}
+		bound = append(bound, value)
+	}
+	for _, name := range slices.Sorted(maps.Keys(namedArgs)) {
+		value, typeName, err := bindArg(namedArgs[name])
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: failed to bind arg %q: %w", ErrInvalidRequest, name, err)
+		}
+		if typeName != "" {
+			types[name] = typeName
+		}
+		bound = append(bound, sql.Named(name, value))
+	}
+
+	if len(types) > 0 {
+		query = castPlaceholders(query, types)
+	}
+	return query, bound, nil
+}
+
+// bindArg converts a JSON decoded argument to a value the driver can bind, returning the annotated type if any.
+func bindArg(arg any) (any, string, error) {
+	if object, ok := arg.(map[string]any); ok && len(object) == 2 {
+		typeName, hasType := object["type"].(string)
+		value, hasValue := object["value"]
+		if hasType && hasValue {
+			typeName = strings.TrimSpace(typeName)
+			if !typeNamePattern.MatchString(typeName) {
+				return nil, "", fmt.Errorf("unsupported type %q", typeName)
+			}
+			text, err := typedValueText(value)
+			if err != nil {
+				return nil, "", err
+			}
+			return text, typeName, nil
+		}
+	}
+	value, err := normalizeArg(arg)
+	return value, "", err
+}
+
+// typedValueText returns the text DuckDB casts to the annotated type.
+func typedValueText(value any) (any, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return v, nil
+	case stdjson.Number:
+		return v.String(), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		return nil, fmt.Errorf("the value of a typed arg must be a string, number, boolean or null, got %T", value)
+	}
+}
+
+// normalizeArg replaces the numbers of an untyped argument, including those nested in lists and structs.
+func normalizeArg(arg any) (any, error) {
+	switch v := arg.(type) {
+	case stdjson.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		if !strings.ContainsAny(v.String(), ".eE") {
+			// Too large for a BIGINT, DuckDB binds it as a HUGEINT
+			if i, ok := new(big.Int).SetString(v.String(), 10); ok {
+				return i, nil
+			}
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q: %w", v, err)
+		}
+		return f, nil
+	case []any:
+		normalized := make([]any, len(v))
+		for i, element := range v {
+			value, err := normalizeArg(element)
+			if err != nil {
+				return nil, err
+			}
+			normalized[i] = value
+		}
+		return normalized, nil
+	case map[string]any:
+		normalized := make(map[string]any, len(v))
+		for key, element := range v {
+			value, err := normalizeArg(element)
+			if err != nil {
+				return nil, err
+			}
+			normalized[key] = value
+		}
+		return normalized, nil
+	default:
+		return arg, nil
+	}
+}
+
+// castPlaceholders wraps the placeholders that have a type in a cast to that type. Placeholders are ?, $1 or $name,
+// string literals, quoted identifiers and comments are left untouched.
+func castPlaceholders(query string, types map[string]string) string {
+	var b strings.Builder
+	write := func(placeholder string, key string) {
+		if typeName, ok := types[key]; ok {
+			fmt.Fprintf(&b, "CAST(%s AS %s)", placeholder, typeName)
+		} else {
+			b.WriteString(placeholder)
+		}
+	}
+
+	position := 0
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			// Quotes are escaped by doubling them, which reads as two consecutive quoted sections
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+2])
+			i += end + 2
+		case strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+1])
+			i += end + 1
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+4])
+			i += end + 4
+		case c == '?':
+			position++
+			write("?", strconv.Itoa(position))
+			i++
+		case c == '$':
+			j := i + 1
+			for j < len(query) && (query[j] == '_' || isAlphanumeric(query[j])) {
+				j++
+			}
+			switch {
+			case j < len(query) && query[j] == '$':
+				// Dollar-quoted string, e.g. $$text$$ or $tag$text$tag$
+				tag := query[i : j+1]
+				end := strings.Index(query[j+1:], tag)
+				if end < 0 {
+					b.WriteString(query[i:])
+					return b.String()
+				}
+				b.WriteString(query[i : j+1+end+len(tag)])
+				i = j + 1 + end + len(tag)
+			case j > i+1:
+				write(query[i:j], query[i+1:j])
+				i = j
+			default:
+				b.WriteByte(c)
+				i++
+			}
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+func isAlphanumeric(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	stdjson "encoding/json"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+	_ "github.com/duckdb/duckdb-go/v2"
+)
+
+func TestNamedArgs(t *testing.T) {
+	ctx := context.Background()
+	dsn := "test_named_args.db"
+	t.Cleanup(func() { os.Remove(dsn) })
+
+	_, err := Execute(ctx, dsn, ducktape.ExecuteRequest{
+		Statements: []ducktape.ExecuteStatement{
+			{Query: `CREATE TABLE test_named_args (id INTEGER, name VARCHAR)`},
+			{
+				Query:     `INSERT INTO test_named_args VALUES ($id, $name), ($id + 1, $name || '!')`,
+				NamedArgs: map[string]any{"name": "Alice", "id": 1},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to insert with named args: %v", err)
+	}
+
+	t.Run("query binds by name regardless of order", func(t *testing.T) {
+		rows, err := Query(ctx, dsn, ducktape.QueryRequest{
+			Query:     "SELECT id, name FROM test_named_args WHERE id >= $min AND name LIKE $pattern ORDER BY id",
+			NamedArgs: map[string]any{"pattern": "Alice%", "min": 2},
+		})
+		if err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+		if len(rows) != 1 || rows[0]["name"] != "Alice!" {
+			t.Errorf("expected Alice!, got %v", rows)
+		}
+	})
+
+	t.Run("paginated query", func(t *testing.T) {
+		response, err := QueryPage(ctx, dsn, ducktape.QueryRequest{
+			Query:     "SELECT id FROM test_named_args WHERE name LIKE $pattern ORDER BY id",
+			NamedArgs: map[string]any{"pattern": "Alice%"},
+			PageSize:  1,
+		})
+		if err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+		if len(response.Rows) != 1 || response.NextCursor == nil {
+			t.Errorf("expected a page of 1 row with a cursor, got %+v", response)
+		}
+	})
+
+	t.Run("explain", func(t *testing.T) {
+		_, err := Explain(ctx, dsn, ducktape.ExplainRequest{
+			Query:     "SELECT * FROM test_named_args WHERE id = $id",
+			NamedArgs: map[string]any{"id": 1},
+		})
+		if err != nil {
+			t.Fatalf("failed to explain: %v", err)
+		}
+	})
+
+	t.Run("args and named args cannot be combined", func(t *testing.T) {
+		_, err := Query(ctx, dsn, ducktape.QueryRequest{
+			Query:     "SELECT $id",
+			Args:      []any{1},
+			NamedArgs: map[string]any{"id": 1},
+		})
+		if !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("expected an invalid request error when combining args and named args, got %v", err)
+		}
+	})
+
+	t.Run("missing named arg", func(t *testing.T) {
+		_, err := Query(ctx, dsn, ducktape.QueryRequest{
+			Query:     "SELECT * FROM test_named_args WHERE id = $id AND name = $name",
+			NamedArgs: map[string]any{"id": 1},
+		})
+		if err == nil {
+			t.Error("expected an error for a missing named arg, got none")
+		}
+	})
+}
+
+func TestCastPlaceholders(t *testing.T) {
+	types := map[string]string{"1": "DECIMAL(38,10)", "3": "DATE", "amount": "HUGEINT"}
+	for query, expected := range map[string]string{
+		"SELECT ?, ?, ?":                    "SELECT CAST(? AS DECIMAL(38,10)), ?, CAST(? AS DATE)",
+		"SELECT $1, $2, $3":                 "SELECT CAST($1 AS DECIMAL(38,10)), $2, CAST($3 AS DATE)",
+		"SELECT $amount, $other":            "SELECT CAST($amount AS HUGEINT), $other",
+		"SELECT '?', 'it''s ?', \"?\", ?":   "SELECT '?', 'it''s ?', \"?\", CAST(? AS DECIMAL(38,10))",
+		"SELECT ? -- what?\n, /* ? */ ?, ?": "SELECT CAST(? AS DECIMAL(38,10)) -- what?\n, /* ? */ ?, CAST(? AS DATE)",
+		"SELECT $$ $amount ? $$, $amount":   "SELECT $$ $amount ? $$, CAST($amount AS HUGEINT)",
+		"SELECT $tag$ ? $tag$, ?":           "SELECT $tag$ ? $tag$, CAST(? AS DECIMAL(38,10))",
+		"SELECT 'unterminated ?":            "SELECT 'unterminated ?",
+	} {
+		if actual := castPlaceholders(query, types); actual != expected {
+			t.Errorf("castPlaceholders(%q): expected %q, got %q", query, expected, actual)
+		}
+	}
+}
+
+func TestTypedArgs(t *testing.T) {
+	ctx := context.Background()
+	dsn := "test_typed_args.db"
+	t.Cleanup(func() { os.Remove(dsn) })
+
+	body := `{"statements": [
+		{"query": "CREATE TABLE test_typed_args (id BIGINT, amount DECIMAL(38,10), created_at TIMESTAMP)"},
+		{"query": "INSERT INTO test_typed_args VALUES (?, ?, ?)", "args": [
+			9007199254740993,
+			{"type": "DECIMAL(38,10)", "value": "12345678901234567890.0123456789"},
+			{"type": "TIMESTAMP", "value": "2024-01-02 03:04:05"}
+		]}
+	]}`
+	request, err := getRequestBody[ducktape.ExecuteRequest](httptest.NewRequest("POST", ducktape.ExecuteRoute, strings.NewReader(body)))
+	if err != nil {
+		t.Fatalf("failed to decode the request: %v", err)
+	}
+	if _, err := Execute(ctx, dsn, request); err != nil {
+		t.Fatalf("failed to insert typed args: %v", err)
+	}
+
+	t.Run("integers stay exact", func(t *testing.T) {
+		rows, err := Query(ctx, dsn, ducktape.QueryRequest{
+			Query: "SELECT id::VARCHAR AS id FROM test_typed_args WHERE id = ?",
+			Args:  []any{stdjson.Number("9007199254740993")},
+		})
+		if err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+		if len(rows) != 1 || rows[0]["id"] != "9007199254740993" {
+			t.Errorf("expected id 9007199254740993, got %v", rows)
+		}
+	})
+
+	t.Run("decimal and timestamp args", func(t *testing.T) {
+		rows, err := Query(ctx, dsn, ducktape.QueryRequest{
+			Query: "SELECT amount::VARCHAR AS amount, $delta + amount > amount AS bigger FROM test_typed_args WHERE created_at < $before",
+			NamedArgs: map[string]any{
+				"delta":  map[string]any{"type": "DECIMAL(38,10)", "value": "0.0000000001"},
+				"before": map[string]any{"type": "TIMESTAMP", "value": "2024-01-02 03:04:06"},
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+		if len(rows) != 1 || rows[0]["amount"] != "12345678901234567890.0123456789" || rows[0]["bigger"] != true {
+			t.Errorf("unexpected result: %v", rows)
+		}
+	})
+
+	t.Run("hugeint", func(t *testing.T) {
+		rows, err := Query(ctx, dsn, ducktape.QueryRequest{
+			Query: "SELECT (? + 1)::VARCHAR AS value",
+			Args:  []any{stdjson.Number("170141183460469231731687303715884105726")},
+		})
+		if err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+		if len(rows) != 1 || rows[0]["value"] != "170141183460469231731687303715884105727" {
+			t.Errorf("unexpected result: %v", rows)
+		}
+	})
+
+	t.Run("invalid type is rejected", func(t *testing.T) {
+		_, err := Query(ctx, dsn, ducktape.QueryRequest{
+			Query: "SELECT ?",
+			Args:  []any{map[string]any{"type": "INTEGER); DROP TABLE test_typed_args; --", "value": "1"}},
+		})
+		if err == nil || !strings.Contains(err.Error(), "unsupported type") {
+			t.Errorf("expected an unsupported type error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,269 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+)
+
+var (
+	// ErrNoCredentials is returned by an [Authenticator] when the request carries none of the credentials it verifies.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials is returned by an [Authenticator] when the credentials of the request do not verify.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// jwtLeeway is the clock skew tolerated when checking the expiry and not-before times of a token.
+const jwtLeeway = time.Minute
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	// Name identifies the caller: the name of its API key, or the subject of its token.
+	Name string
+	// Method is how the caller authenticated, e.g. "api-key" or "jwt".
+	Method string
+}
+
+// Authenticator verifies the credentials of a request.
+type Authenticator interface {
+	// Authenticate returns the caller of the request, [ErrNoCredentials] when the request carries no credentials this
+	// authenticator understands, or an error wrapping [ErrInvalidCredentials] when they do not verify.
+	Authenticate(r *http.Request) (Principal, error)
+}
+
+type principalContextKey struct{}
+
+// WithPrincipal returns a context carrying the caller of a request, for servers that authenticate requests themselves
+// instead of going through [Authenticate].
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns the caller authenticated by [Authenticate], if any.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey{}).(Principal)
+	return principal, ok
+}
+
+// Authenticate wraps a handler so that only requests that one of the authenticators accepts reach it, the caller is
+// then available through [PrincipalFromContext]. Other requests are rejected with 401 Unauthorized. Without any
+// authenticator, the handler is returned as is.
+func Authenticate(next http.Handler, authenticators ...Authenticator) http.Handler {
+	if len(authenticators) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, authenticator := range authenticators {
+			principal, err := authenticator.Authenticate(r)
+			if errors.Is(err, ErrNoCredentials) {
+				continue
+			}
+			if err != nil {
+				handleUnauthorizedJSON(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+			return
+		}
+		handleUnauthorizedJSON(w, r, fmt.Errorf("authentication is required: %w", ErrNoCredentials))
+	})
+}
+
+func handleUnauthorizedJSON(w http.ResponseWriter, r *http.Request, err error) {
+	LoggerFromContext(r.Context()).Error("returning unauthorized", slog.Any("error", err), slog.String("clientAddress", r.RemoteAddr))
+	w.Header().Set("WWW-Authenticate", `Bearer realm="ducktape"`)
+	errMsg := err.Error()
+	writeErrorJSON(w, http.StatusUnauthorized, ducktape.ErrorResponse{Error: &errMsg}, err)
+}
+
+// bearerToken returns the token of the Authorization header, if it holds a bearer token.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// APIKeyAuthenticator accepts static API keys, sent in the [ducktape.APIKeyHeader] header or as a bearer token.
+type APIKeyAuthenticator struct {
+	// principals maps the SHA-256 digest of each key to the name of its principal, so lookups do not leak the keys
+	// through timing.
+	principals map[[sha256.Size]byte]string
+}
+
+// NewAPIKeyAuthenticator returns an authenticator for the given keys, mapped to the name of the principal they identify.
+func NewAPIKeyAuthenticator(keys map[string]string) *APIKeyAuthenticator {
+	principals := make(map[[sha256.Size]byte]string, len(keys))
+	for key, name := range keys {
+		principals[sha256.Sum256([]byte(key))] = name
+	}
+	return &APIKeyAuthenticator{principals: principals}
+}
+
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	key := r.Header.Get(ducktape.APIKeyHeader)
+	if key == "" {
+		token, ok := bearerToken(r)
+		// JWTs are left to the JWT authenticator
+		if !ok || strings.Count(token, ".") == 2 {
+			return Principal{}, ErrNoCredentials
+		}
+		key = token
+	}
+
+	name, ok := a.principals[sha256.Sum256([]byte(key))]
+	if !ok {
+		return Principal{}, fmt.Errorf("%w: unknown API key", ErrInvalidCredentials)
+	}
+	return Principal{Name: name, Method: "api-key"}, nil
+}
+
+// JWTAuthenticator accepts JSON Web Tokens sent as bearer tokens and signed with HMAC (HS256, HS384 or HS512) by one
+// of the configured keys. The subject of the token names the principal.
+type JWTAuthenticator struct {
+	// keys maps key IDs to their secret, tokens without a "kid" header are checked against every key.
+	keys map[string][]byte
+	// issuer and audience, when set, must match the "iss" and "aud" claims.
+	issuer   string
+	audience string
+	now      func() time.Time
+}
+
+func NewJWTAuthenticator(keys map[string][]byte, issuer string, audience string) *JWTAuthenticator {
+	return &JWTAuthenticator{keys: keys, issuer: issuer, audience: audience, now: time.Now}
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt *int64   `json:"exp"`
+	NotBefore *int64   `json:"nbf"`
+}
+
+// audience is the "aud" claim, which is either a single string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
+
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	token, ok := bearerToken(r)
+	if !ok || strings.Count(token, ".") != 2 {
+		return Principal{}, ErrNoCredentials
+	}
+	claims, err := a.verify(token)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+	return Principal{Name: claims.Subject, Method: "jwt"}, nil
+}
+
+func (a *JWTAuthenticator) verify(token string) (jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return jwtClaims{}, fmt.Errorf("failed to decode the token header: %w", err)
+	}
+
+	var newHash func() hash.Hash
+	switch header.Algorithm {
+	case "HS256":
+		newHash = sha256.New
+	case "HS384":
+		newHash = sha512.New384
+	case "HS512":
+		newHash = sha512.New
+	default:
+		return jwtClaims{}, fmt.Errorf("unsupported signing algorithm %q", header.Algorithm)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return jwtClaims{}, fmt.Errorf("failed to decode the token signature: %w", err)
+	}
+	if !a.verifySignature(header.KeyID, newHash, parts[0]+"."+parts[1], signature) {
+		return jwtClaims{}, errors.New("signature does not match")
+	}
+
+	var claims jwtClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return jwtClaims{}, fmt.Errorf("failed to decode the token claims: %w", err)
+	}
+	now := a.now()
+	if claims.ExpiresAt == nil {
+		return jwtClaims{}, errors.New("token has no expiry")
+	}
+	if now.After(time.Unix(*claims.ExpiresAt, 0).Add(jwtLeeway)) {
+		return jwtClaims{}, errors.New("token has expired")
+	}
+	if claims.NotBefore != nil && now.Add(jwtLeeway).Before(time.Unix(*claims.NotBefore, 0)) {
+		return jwtClaims{}, errors.New("token is not valid yet")
+	}
+	if a.issuer != "" && claims.Issuer != a.issuer {
+		return jwtClaims{}, fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if a.audience != "" && !slices.Contains(claims.Audience, a.audience) {
+		return jwtClaims{}, fmt.Errorf("token is not intended for audience %q", a.audience)
+	}
+	if claims.Subject == "" {
+		return jwtClaims{}, errors.New("token has no subject")
+	}
+	return claims, nil
+}
+
+func (a *JWTAuthenticator) verifySignature(keyID string, newHash func() hash.Hash, signed string, signature []byte) bool {
+	verify := func(key []byte) bool {
+		mac := hmac.New(newHash, key)
+		mac.Write([]byte(signed))
+		return hmac.Equal(mac.Sum(nil), signature)
+	}
+	if keyID != "" {
+		key, ok := a.keys[keyID]
+		return ok && verify(key)
+	}
+	for _, key := range a.keys {
+		if verify(key) {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeJWTPart(part string, v any) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(decoded, v)
+}
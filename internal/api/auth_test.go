@@ -0,0 +1,122 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+)
+
+func signJWT(t *testing.T, header map[string]any, claims map[string]any, key []byte) string {
+	t.Helper()
+	encode := func(v any) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := encode(header) + "." + encode(claims)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestAuthenticate(t *testing.T) {
+	key := []byte("secret")
+	handler := Authenticate(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, _ := PrincipalFromContext(r.Context())
+			w.Write([]byte(principal.Method + ":" + principal.Name))
+		}),
+		NewAPIKeyAuthenticator(map[string]string{"key-1": "etl"}),
+		NewJWTAuthenticator(map[string][]byte{"v1": key}, "issuer", "ducktape"),
+	)
+	serve := func(headers map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", ducktape.QueryRoute, nil)
+		for name, value := range headers {
+			r.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	now := time.Now().Unix()
+	valid := map[string]any{"sub": "alice", "iss": "issuer", "aud": []string{"other", "ducktape"}, "exp": now + 60}
+	hs256 := map[string]any{"alg": "HS256", "typ": "JWT", "kid": "v1"}
+
+	for name, tc := range map[string]struct {
+		headers  map[string]string
+		expected string
+	}{
+		"api key header": {map[string]string{ducktape.APIKeyHeader: "key-1"}, "api-key:etl"},
+		"api key bearer": {map[string]string{"Authorization": "Bearer key-1"}, "api-key:etl"},
+		"jwt":            {map[string]string{"Authorization": "Bearer " + signJWT(t, hs256, valid, key)}, "jwt:alice"},
+		"jwt without kid": {
+			map[string]string{"Authorization": "bearer " + signJWT(t, map[string]any{"alg": "HS256"}, valid, key)},
+			"jwt:alice",
+		},
+	} {
+		w := serve(tc.headers)
+		if w.Code != http.StatusOK || w.Body.String() != tc.expected {
+			t.Errorf("%s: expected 200 with %q, got %d with %q", name, tc.expected, w.Code, w.Body.String())
+		}
+	}
+
+	claims := func(overrides map[string]any) map[string]any {
+		merged := map[string]any{}
+		for k, v := range valid {
+			merged[k] = v
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(merged, k)
+			} else {
+				merged[k] = v
+			}
+		}
+		return merged
+	}
+	for name, headers := range map[string]map[string]string{
+		"no credentials":   {},
+		"unknown api key":  {ducktape.APIKeyHeader: "key-2"},
+		"basic auth":       {"Authorization": "Basic ZXRsOmtleS0x"},
+		"wrong key":        {"Authorization": "Bearer " + signJWT(t, hs256, valid, []byte("other"))},
+		"unknown kid":      {"Authorization": "Bearer " + signJWT(t, map[string]any{"alg": "HS256", "kid": "v2"}, valid, key)},
+		"none algorithm":   {"Authorization": "Bearer " + signJWT(t, map[string]any{"alg": "none"}, valid, key)},
+		"expired":          {"Authorization": "Bearer " + signJWT(t, hs256, claims(map[string]any{"exp": now - 120}), key)},
+		"no expiry":        {"Authorization": "Bearer " + signJWT(t, hs256, claims(map[string]any{"exp": nil}), key)},
+		"not valid yet":    {"Authorization": "Bearer " + signJWT(t, hs256, claims(map[string]any{"nbf": now + 120}), key)},
+		"wrong issuer":     {"Authorization": "Bearer " + signJWT(t, hs256, claims(map[string]any{"iss": "other"}), key)},
+		"wrong audience":   {"Authorization": "Bearer " + signJWT(t, hs256, claims(map[string]any{"aud": "other"}), key)},
+		"no subject":       {"Authorization": "Bearer " + signJWT(t, hs256, claims(map[string]any{"sub": nil}), key)},
+		"tampered payload": {"Authorization": "Bearer " + tamper(signJWT(t, hs256, valid, key))},
+	} {
+		w := serve(headers)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected status 401, got %d", name, w.Code)
+			continue
+		}
+		if w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: expected a WWW-Authenticate header", name)
+		}
+		var response ducktape.ErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.Error == nil {
+			t.Errorf("%s: expected an error response, got %q", name, w.Body.String())
+		}
+	}
+}
+
+// tamper replaces the claims of a token while keeping its signature.
+func tamper(token string) string {
+	parts := strings.Split(token, ".")
+	parts[1] = base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin","exp":9999999999}`))
+	return strings.Join(parts, ".")
+}
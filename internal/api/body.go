@@ -0,0 +1,50 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+)
+
+// ErrRequestTooLarge is returned when a request body is over the limit of its route.
+var ErrRequestTooLarge = errors.New("request body is too large")
+
+// maxBodyBytes returns the body size limit of a route pattern, e.g. "POST /api/query". Zero means no limit.
+func maxBodyBytes(pattern string) int64 {
+	_, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		path = pattern
+	}
+	if limit, ok := options.RouteMaxBodyBytes[path]; ok {
+		return limit
+	}
+	if path == ducktape.AppendRoute {
+		// Appends are streamed rather than buffered, so they are only limited when their route is
+		return 0
+	}
+	return options.MaxBodyBytes
+}
+
+// limitRequestBody rejects requests whose declared length is over the limit of their route, and caps the body of the
+// others, which fail with [http.MaxBytesError] once they read past it.
+func limitRequestBody(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := maxBodyBytes(r.Pattern)
+		if limit <= 0 {
+			handler(w, r)
+			return
+		}
+
+		if r.ContentLength > limit {
+			err := fmt.Errorf("%w: the limit is %d bytes", ErrRequestTooLarge, limit)
+			errMsg := err.Error()
+			handleRequestTooLargeJSON(w, ducktape.ErrorResponse{Error: &errMsg}, err)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		handler(w, r)
+	}
+}
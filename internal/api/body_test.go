@@ -0,0 +1,68 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+	_ "github.com/duckdb/duckdb-go/v2"
+)
+
+func TestLimitRequestBody(t *testing.T) {
+	dsn := "test_limit_request_body.db"
+	Configure(Options{MaxBodyBytes: 64, RouteMaxBodyBytes: map[string]int64{ducktape.AppendRoute: 256}})
+	t.Cleanup(func() {
+		Configure(Options{})
+		os.Remove(dsn)
+	})
+
+	mux := http.NewServeMux()
+	RegisterApiRoutes(mux)
+	serve := func(route string, body io.Reader, contentLength int64) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", route, body)
+		r.ContentLength = contentLength
+		r.ProtoMajor = 2
+		r.Header.Set(ducktape.DuckDBConnectionStringHeader, dsn)
+		r.Header.Set(ducktape.DuckDBDatabaseHeader, "test_limit_request_body")
+		r.Header.Set(ducktape.DuckDBTableHeader, "events")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	create := `{"statements":[{"query":"CREATE TABLE events (id INTEGER)"}]}`
+	if w := serve(ducktape.ExecuteRoute, strings.NewReader(create), int64(len(create))); w.Code != http.StatusOK {
+		t.Fatalf("expected a small request to be served, got %d: %s", w.Code, w.Body.String())
+	}
+
+	query := `{"query":"SELECT 1 AS padding` + strings.Repeat(" ", 64) + `"}`
+	if w := serve(ducktape.QueryRoute, strings.NewReader(query), int64(len(query))); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected a declared length over the limit to be rejected, got %d", w.Code)
+	}
+	// Chunked requests have no declared length, they fail once their body is read past the limit
+	if w := serve(ducktape.QueryRoute, strings.NewReader(query), -1); w.Code != http.StatusRequestEntityTooLarge ||
+		!strings.Contains(w.Body.String(), ErrRequestTooLarge.Error()) {
+		t.Errorf("expected a chunked body over the limit to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+
+	rows := strings.Repeat(`{"rv":[1]}`+"\n", 10)
+	if w := serve(ducktape.AppendRoute, strings.NewReader(rows), -1); w.Code != http.StatusOK {
+		t.Errorf("expected the append route to have its own limit, got %d: %s", w.Code, w.Body.String())
+	}
+	rows = strings.Repeat(`{"rv":[1]}`+"\n", 100)
+	if w := serve(ducktape.AppendRoute, strings.NewReader(rows), -1); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected an append over its limit to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+
+	Configure(Options{MaxBodyBytes: 64})
+	if limit := maxBodyBytes("POST " + ducktape.AppendRoute); limit != 0 {
+		t.Errorf("expected appends not to be limited unless their route is, got %d", limit)
+	}
+	if w := serve(ducktape.PingRoute, nil, 0); w.Code == http.StatusRequestEntityTooLarge {
+		t.Errorf("expected a request without a body to be served, got %d", w.Code)
+	}
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+)
+
+const defaultCursorIdleTimeout = 5 * time.Minute
+
+var (
+	ErrCursorNotFound    = errors.New("cursor not found")
+	ErrCursorDSNMismatch = errors.New("cursor was opened with a different connection string")
+)
+
+var cursors = newCursorStore(defaultCursorIdleTimeout)
+
+// cursor is a paginated query whose result set is held open between requests.
+type cursor struct {
+	id       string
+	dsn      string
+	pageSize int
+	db       *sql.DB
+	conn     *sql.Conn
+	rows     *sql.Rows
+	columns  []string
+	// principal is the caller that opened the cursor, only it can fetch its pages.
+	principal string
+	// next is the first row of the following page, it was scanned by the previous page to learn whether more rows remain.
+	next map[string]any
+	// interrupt cancels the held query, it is triggered when the request fetching a page is cancelled.
+	interrupt context.CancelFunc
+
+	// Guarded by cursorStore.mu
+	idleTimer *time.Timer
+}
+
+func (c *cursor) close() {
+	c.interrupt()
+	c.rows.Close()
+	c.conn.Close()
+	c.db.Close()
+}
+
+// cursorStore holds paginated queries and closes the ones that have been idle for longer than idleTimeout.
+type cursorStore struct {
+	idleTimeout time.Duration
+
+	mu      sync.Mutex
+	cursors map[string]*cursor
+}
+
+func newCursorStore(idleTimeout time.Duration) *cursorStore {
+	return &cursorStore{
+		idleTimeout: idleTimeout,
+		cursors:     make(map[string]*cursor),
+	}
+}
+
+// open runs the query and returns its first page, the result set is held open if more rows remain.
+func (s *cursorStore) open(ctx context.Context, dsn string, request ducktape.QueryRequest) (ducktape.QueryResponse, error) {
+	query, args, err := bindArgs(request.Query, request.Args, request.NamedArgs)
+	if err != nil {
+		return ducktape.QueryResponse{}, err
+	}
+
+	db, err := openDB(ctx, dsn, "queries")
+	if err != nil {
+		return ducktape.QueryResponse{}, err
+	}
+
+	// The result set must not be bound to the request context, otherwise it would be closed as soon as the first page is returned.
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		db.Close()
+		return ducktape.QueryResponse{}, fmt.Errorf("failed to get a connection for queries(%q): %w", "duckdb", err)
+	}
+
+	pageSize := request.PageSize
+	if options.MaxResultRows > 0 {
+		pageSize = min(pageSize, options.MaxResultRows)
+	}
+	rowsCtx, interrupt := context.WithCancel(context.Background())
+	c := &cursor{id: rand.Text(), dsn: dsn, pageSize: pageSize, db: db, conn: conn, principal: principalName(ctx), interrupt: interrupt}
+	stop := context.AfterFunc(ctx, interrupt)
+
+	LoggerFromContext(ctx).Debug("querying duckdb", slog.String("query", request.Query), slog.Any("args", request.Args), slog.Any("namedArgs", request.NamedArgs), slog.Int("pageSize", pageSize))
+
+	_, span := startSpan(ctx, "duckdb.query", queryText(request.Query))
+	c.rows, err = conn.QueryContext(rowsCtx, query, args...)
+	endSpan(span, err)
+	if err != nil {
+		stop()
+		interrupt()
+		conn.Close()
+		db.Close()
+		return ducktape.QueryResponse{}, fmt.Errorf("failed to query the DB: %w", err)
+	}
+
+	c.columns, err = c.rows.Columns()
+	if err != nil {
+		stop()
+		c.close()
+		return ducktape.QueryResponse{}, fmt.Errorf("failed to convert rows to objects: %w", err)
+	}
+	return s.readPage(ctx, c, stop)
+}
+
+// fetch returns the next page of a cursor. The cursor is detached from the store while the page is read, so a
+// concurrent fetch of the same cursor fails instead of interleaving rows.
+func (s *cursorStore) fetch(ctx context.Context, id string, dsn string) (ducktape.QueryResponse, error) {
+	s.mu.Lock()
+	c, ok := s.cursors[id]
+	if !ok {
+		s.mu.Unlock()
+		return ducktape.QueryResponse{}, fmt.Errorf("%w: %q", ErrCursorNotFound, id)
+	}
+	if err := checkOwner(ctx, c.principal); err != nil {
+		s.mu.Unlock()
+		return ducktape.QueryResponse{}, fmt.Errorf("cursor %q: %w", id, err)
+	}
+	if dsn != "" && databaseKey(dsn) != databaseKey(c.dsn) {
+		s.mu.Unlock()
+		return ducktape.QueryResponse{}, fmt.Errorf("%w: %q", ErrCursorDSNMismatch, id)
+	}
+	delete(s.cursors, id)
+	c.idleTimer.Stop()
+	s.mu.Unlock()
+
+	return s.readPage(ctx, c, context.AfterFunc(ctx, c.interrupt))
+}
+
+// readPage reads the next page of a detached cursor and puts it back in the store if more rows remain, otherwise the
+// cursor is closed. stop unregisters the request's interruption of the held query.
+func (s *cursorStore) readPage(ctx context.Context, c *cursor, stop func() bool) (ducktape.QueryResponse, error) {
+	page, err := readPage(ctx, c.rows, c.columns, c.next, c.pageSize, options.MaxResultBytes)
+	if !stop() {
+		// The request was cancelled, which interrupted the held query as well.
+		c.close()
+		return ducktape.QueryResponse{}, fmt.Errorf("query was interrupted: %w", ctx.Err())
+	}
+	if err != nil {
+		c.close()
+		return ducktape.QueryResponse{}, err
+	}
+
+	response := ducktape.QueryResponse{Rows: page.objects}
+	if page.next == nil {
+		c.close()
+		return response, nil
+	}
+
+	c.next = page.next
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cursors[c.id] = c
+	c.idleTimer = time.AfterFunc(s.idleTimeout, func() { s.expire(c) })
+	response.NextCursor = &c.id
+	return response, nil
+}
+
+// close closes every cursor held between requests.
+func (s *cursorStore) close() {
+	s.mu.Lock()
+	open := make([]*cursor, 0, len(s.cursors))
+	for _, c := range s.cursors {
+		c.idleTimer.Stop()
+		open = append(open, c)
+	}
+	clear(s.cursors)
+	s.mu.Unlock()
+
+	for _, c := range open {
+		c.close()
+	}
+}
+
+func (s *cursorStore) expire(c *cursor) {
+	s.mu.Lock()
+	if s.cursors[c.id] != c {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.cursors, c.id)
+	s.mu.Unlock()
+
+	slog.Warn("closing idle cursor", slog.String("cursor", c.id), slog.Duration("idleTimeout", s.idleTimeout))
+	c.close()
+}
+
+// FetchPage returns the next page of a paginated query started by [QueryPage].
+// Cursors that are not fetched for longer than the idle timeout are closed automatically.
+// An empty DSN skips the check that the cursor was opened against the same connection string.
+func FetchPage(ctx context.Context, cursorID string, dsn string) (ducktape.QueryResponse, error) {
+	return cursors.fetch(ctx, cursorID, dsn)
+}
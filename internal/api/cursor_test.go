@@ -0,0 +1,220 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+	_ "github.com/duckdb/duckdb-go/v2"
+)
+
+func TestQueryPage(t *testing.T) {
+	ctx := context.Background()
+	dsn := "test_query_page.db"
+	t.Cleanup(func() {
+		Configure(Options{})
+		os.Remove(dsn)
+	})
+
+	_, err := Execute(ctx, dsn, ducktape.ExecuteRequest{
+		Statements: []ducktape.ExecuteStatement{
+			{Query: `CREATE TABLE test_query_page AS SELECT range AS id FROM range(10)`},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	t.Run("buffered result is truncated at the row limit", func(t *testing.T) {
+		Configure(Options{MaxResultRows: 4})
+		response, err := QueryPage(ctx, dsn, ducktape.QueryRequest{Query: "SELECT id FROM test_query_page ORDER BY id"})
+		if err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+		if len(response.Rows) != 4 {
+			t.Errorf("expected 4 rows, got %d", len(response.Rows))
+		}
+		if !response.Truncated {
+			t.Error("expected the response to be truncated")
+		}
+	})
+
+	t.Run("query reports the truncation", func(t *testing.T) {
+		Configure(Options{MaxResultRows: 4})
+		rows, err := Query(ctx, dsn, ducktape.QueryRequest{Query: "SELECT id FROM test_query_page ORDER BY id"})
+		if !errors.Is(err, ErrResultTruncated) {
+			t.Errorf("expected ErrResultTruncated, got %v", err)
+		}
+		if len(rows) != 4 {
+			t.Errorf("expected the 4 rows that fit, got %d", len(rows))
+		}
+	})
+
+	t.Run("buffered result is truncated at the byte limit", func(t *testing.T) {
+		// Each row is encoded as {"id":N}, which is 8 bytes
+		Configure(Options{MaxResultBytes: 20})
+		response, err := QueryPage(ctx, dsn, ducktape.QueryRequest{Query: "SELECT id FROM test_query_page ORDER BY id"})
+		if err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+		if len(response.Rows) != 2 {
+			t.Errorf("expected 2 rows, got %d", len(response.Rows))
+		}
+		if !response.Truncated {
+			t.Error("expected the response to be truncated")
+		}
+	})
+
+	t.Run("result that fits is not truncated", func(t *testing.T) {
+		Configure(Options{MaxResultRows: 10})
+		response, err := QueryPage(ctx, dsn, ducktape.QueryRequest{Query: "SELECT id FROM test_query_page"})
+		if err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+		if len(response.Rows) != 10 || response.Truncated {
+			t.Errorf("expected 10 rows without truncation, got %d (truncated=%t)", len(response.Rows), response.Truncated)
+		}
+	})
+
+	t.Run("pages through the result with a cursor", func(t *testing.T) {
+		Configure(Options{})
+		response, err := QueryPage(ctx, dsn, ducktape.QueryRequest{Query: "SELECT id FROM test_query_page ORDER BY id", PageSize: 4})
+		if err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+
+		var ids []int64
+		pages := 1
+		for {
+			for _, row := range response.Rows {
+				ids = append(ids, row["id"].(int64))
+			}
+			if response.NextCursor == nil {
+				break
+			}
+
+			cursor := *response.NextCursor
+			response, err = FetchPage(ctx, cursor, dsn)
+			if err != nil {
+				t.Fatalf("failed to fetch page %d: %v", pages+1, err)
+			}
+			pages++
+		}
+
+		if pages != 3 {
+			t.Errorf("expected 3 pages, got %d", pages)
+		}
+		if len(ids) != 10 {
+			t.Fatalf("expected 10 rows, got %d", len(ids))
+		}
+		for i, id := range ids {
+			if id != int64(i) {
+				t.Errorf("expected row %d to have id %d, got %d", i, i, id)
+			}
+		}
+	})
+
+	t.Run("page size is capped by the row limit", func(t *testing.T) {
+		Configure(Options{MaxResultRows: 3})
+		response, err := QueryPage(ctx, dsn, ducktape.QueryRequest{Query: "SELECT id FROM test_query_page", PageSize: 100})
+		if err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+		if len(response.Rows) != 3 {
+			t.Errorf("expected 3 rows, got %d", len(response.Rows))
+		}
+		if response.NextCursor == nil {
+			t.Fatal("expected a cursor for the remaining rows")
+		}
+
+		_, err = FetchPage(ctx, *response.NextCursor, "other.db")
+		if !errors.Is(err, ErrCursorDSNMismatch) {
+			t.Errorf("expected ErrCursorDSNMismatch, got %v", err)
+		}
+	})
+
+	t.Run("equivalent DSN", func(t *testing.T) {
+		Configure(Options{DSNAliases: map[string]string{"pages": dsn}})
+		response, err := QueryPage(ctx, "pages", ducktape.QueryRequest{Query: "SELECT id FROM test_query_page", PageSize: 2})
+		if err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+
+		response, err = FetchPage(ctx, *response.NextCursor, "./"+dsn)
+		if err != nil {
+			t.Fatalf("expected an equivalent DSN to fetch the next page, got %v", err)
+		}
+		if len(response.Rows) != 2 {
+			t.Errorf("expected 2 rows, got %d", len(response.Rows))
+		}
+		if response.NextCursor != nil {
+			FetchPage(ctx, *response.NextCursor, "")
+		}
+	})
+
+	t.Run("cursor of another principal", func(t *testing.T) {
+		alice := WithPrincipal(ctx, Principal{Name: "alice"})
+		response, err := QueryPage(alice, dsn, ducktape.QueryRequest{Query: "SELECT id FROM test_query_page", PageSize: 2})
+		if err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+
+		if _, err := FetchPage(WithPrincipal(ctx, Principal{Name: "bob"}), *response.NextCursor, ""); !errors.Is(err, ErrForbidden) {
+			t.Errorf("expected ErrForbidden, got %v", err)
+		}
+		if _, err := FetchPage(alice, *response.NextCursor, ""); err != nil {
+			t.Errorf("expected the owner to fetch the next page, got %v", err)
+		}
+	})
+
+	t.Run("unknown cursor", func(t *testing.T) {
+		_, err := FetchPage(ctx, "does-not-exist", "")
+		if !errors.Is(err, ErrCursorNotFound) {
+			t.Errorf("expected ErrCursorNotFound, got %v", err)
+		}
+	})
+
+	t.Run("exhausted cursor is closed", func(t *testing.T) {
+		Configure(Options{})
+		response, err := QueryPage(ctx, dsn, ducktape.QueryRequest{Query: "SELECT id FROM test_query_page", PageSize: 5})
+		if err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+		cursor := *response.NextCursor
+
+		response, err = FetchPage(ctx, cursor, "")
+		if err != nil {
+			t.Fatalf("failed to fetch: %v", err)
+		}
+		if len(response.Rows) != 5 || response.NextCursor != nil {
+			t.Errorf("expected the last 5 rows without a cursor, got %d rows", len(response.Rows))
+		}
+
+		if _, err := FetchPage(ctx, cursor, ""); !errors.Is(err, ErrCursorNotFound) {
+			t.Errorf("expected ErrCursorNotFound after the last page, got %v", err)
+		}
+	})
+}
+
+func TestCursorStoreExpire(t *testing.T) {
+	ctx := context.Background()
+	dsn := "test_cursor_expire.db"
+	t.Cleanup(func() { os.Remove(dsn) })
+
+	store := newCursorStore(defaultCursorIdleTimeout)
+	response, err := store.open(ctx, dsn, ducktape.QueryRequest{Query: "SELECT * FROM range(10)", PageSize: 2})
+	if err != nil {
+		t.Fatalf("failed to open a cursor: %v", err)
+	}
+
+	store.mu.Lock()
+	c := store.cursors[*response.NextCursor]
+	store.mu.Unlock()
+	store.expire(c)
+
+	if _, err := store.fetch(ctx, *response.NextCursor, ""); !errors.Is(err, ErrCursorNotFound) {
+		t.Errorf("expected ErrCursorNotFound after expiry, got %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/duckdb/duckdb-go/v2"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// openDB opens and validates a DuckDB handle for a DSN sent by a client, after applying the DSN policy of [Options].
+// The operation name is only used to label errors.
+func openDB(ctx context.Context, dsn string, operation string) (db *sql.DB, err error) {
+	ctx, span := startSpan(ctx, "duckdb.open", attribute.String("ducktape.operation", operation))
+	defer func() { endSpan(span, err) }()
+
+	dsn, err = resolveDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	var initFn func(driver.ExecerContext) error
+	if options.DataDir != "" {
+		initFn = restrictFileAccess
+	}
+	connector, err := duckdb.NewConnector(dsn, initFn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start a SQL client for %s(%q): %w", operation, "duckdb", err)
+	}
+	tracked := &trackedConnector{Connector: connector}
+	tracked.path = dsnPath(dsn)
+	db = sql.OpenDB(tracked)
+
+	if err = db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to validate the DB connection for %s(%q): %w", operation, "duckdb", err)
+	}
+	openDatabases.add(tracked, db)
+	return db, nil
+}
+
+// openDatabases tracks the databases opened by [openDB] until they are closed, for the metrics.
+var openDatabases = &databaseRegistry{databases: make(map[*trackedConnector]*sql.DB)}
+
+type databaseRegistry struct {
+	mu        sync.Mutex
+	databases map[*trackedConnector]*sql.DB
+}
+
+func (r *databaseRegistry) add(connector *trackedConnector, db *sql.DB) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.databases[connector] = db
+}
+
+func (r *databaseRegistry) remove(connector *trackedConnector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.databases, connector)
+}
+
+// list returns the open databases by their path. They are not held open, so a database may be closed while the caller
+// uses it.
+func (r *databaseRegistry) list() map[*trackedConnector]*sql.DB {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return maps.Clone(r.databases)
+}
+
+// trackedConnector removes its database from [openDatabases] when the [sql.DB] it belongs to closes it.
+type trackedConnector struct {
+	*duckdb.Connector
+	path string
+}
+
+func (c *trackedConnector) Close() error {
+	openDatabases.remove(c)
+	return c.Connector.Close()
+}
+
+// openScratchDB opens a private in-memory database for the server's own use, it is not subject to the DSN policy.
+func openScratchDB(operation string) (*sql.DB, error) {
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start a SQL client for %s(%q): %w", operation, "duckdb", err)
+	}
+	return db, nil
+}
+
+// checkpointDB writes the WAL of a file database to the database file, so it reopens without a replay. Failures are
+// only logged: DuckDB checkpoints again when the last handle to the database closes.
+func checkpointDB(db *sql.DB, dsn string) {
+	path := dsnPath(dsn)
+	if isInMemoryDSN(path) {
+		return
+	}
+	if _, err := db.ExecContext(context.Background(), "CHECKPOINT"); err != nil {
+		slog.Warn("failed to checkpoint the database", slog.String("dsn", path), slog.Any("error", err))
+	}
+}
+
+// restrictFileAccess confines the files that SQL can read and write (read_csv, COPY, ATTACH, ...) to the data directory
+// and the job directory, and disables extension installs and remote access. The settings belong to the database
+// instance and cannot be changed back once external access is disabled, so only the first connection applies them.
+func restrictFileAccess(execer driver.ExecerContext) error {
+	ctx := context.Background()
+	rows, err := execer.(driver.QueryerContext).QueryContext(ctx, "SELECT current_setting('enable_external_access')", nil)
+	if err != nil {
+		return fmt.Errorf("failed to read the external access setting: %w", err)
+	}
+	values := make([]driver.Value, 1)
+	err = rows.Next(values)
+	rows.Close()
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read the external access setting: %w", err)
+	}
+	if enabled, _ := values[0].(bool); !enabled {
+		return nil
+	}
+
+	directories := make([]string, 0, 2)
+	for _, directory := range []string{options.DataDir, jobDir()} {
+		absolute, err := filepath.Abs(directory)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %q: %w", directory, err)
+		}
+		directories = append(directories, quoteLiteral(absolute+string(filepath.Separator)))
+	}
+	if _, err := execer.ExecContext(ctx, fmt.Sprintf("SET allowed_directories = [%s]", strings.Join(directories, ", ")), nil); err != nil {
+		return fmt.Errorf("failed to set the allowed directories: %w", err)
+	}
+	if _, err := execer.ExecContext(ctx, "SET enable_external_access = false", nil); err != nil {
+		return fmt.Errorf("failed to disable external access: %w", err)
+	}
+	return nil
+}
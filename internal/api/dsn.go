@@ -0,0 +1,192 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrDSNNotAllowed is returned for DSNs that the server-wide DSN policy rejects.
+var ErrDSNNotAllowed = errors.New("DSN is not allowed")
+
+// sandboxOptions are the DuckDB settings that would lift the filesystem sandbox, clients can never set them.
+var sandboxOptions = []string{
+	"enable_external_access",
+	"allowed_directories",
+	"allowed_paths",
+	"lock_configuration",
+	"allow_unsigned_extensions",
+	"allow_community_extensions",
+	"extension_directory",
+	"temp_directory",
+}
+
+// resolveDSN applies the DSN policy of [Options] to a DSN sent by a client and returns the DSN to open:
+//   - the name of a [Options.DSNAliases] entry is replaced by its DSN, the options the client adds still go through
+//     the checks below;
+//   - with [Options.DataDir] set, relative paths are resolved against it, paths that end up outside of it (including
+//     through symlinks) are rejected, and so are in-memory databases unless [Options.AllowInMemory] is set;
+//   - options must be in [Options.AllowedDSNOptions] when it is set, and never in [Options.DeniedDSNOptions];
+//   - the [Options.DSNDefaults] that the DSN does not set are added to it.
+func resolveDSN(dsn string) (string, error) {
+	path, rawQuery, _ := strings.Cut(dsn, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the options of the DSN: %w", err)
+	}
+	for name := range query {
+		if err := checkDSNOption(name); err != nil {
+			return "", err
+		}
+	}
+
+	if alias, ok := options.DSNAliases[path]; ok {
+		// Aliases come from the server configuration, their own path and options are trusted
+		aliasPath, aliasQuery, _ := strings.Cut(alias, "?")
+		aliasOptions, err := url.ParseQuery(aliasQuery)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse the options of DSN alias %q: %w", path, err)
+		}
+		for name, values := range query {
+			aliasOptions[name] = values
+		}
+		if options.DataDir != "" && !isInMemoryDSN(aliasPath) && !filepath.IsAbs(aliasPath) {
+			aliasPath = filepath.Join(options.DataDir, aliasPath)
+		}
+		path, rawQuery = aliasPath, aliasOptions.Encode()
+	} else if options.DataDir != "" {
+		if isInMemoryDSN(path) {
+			if !options.AllowInMemory {
+				return "", fmt.Errorf("%w: in-memory databases are disabled", ErrDSNNotAllowed)
+			}
+		} else if path, err = sandboxPath(path); err != nil {
+			return "", err
+		}
+	}
+
+	rawQuery, err = addDSNDefaults(rawQuery)
+	if err != nil {
+		return "", err
+	}
+	if rawQuery == "" {
+		return path, nil
+	}
+	return path + "?" + rawQuery, nil
+}
+
+// addDSNDefaults adds the [Options.DSNDefaults] that are missing from the options of a DSN. Options are
+// case-insensitive, so "THREADS=2" overrides a default for "threads".
+func addDSNDefaults(rawQuery string) (string, error) {
+	if len(options.DSNDefaults) == 0 {
+		return rawQuery, nil
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the options of the DSN: %w", err)
+	}
+	set := make(map[string]bool, len(query))
+	for name := range query {
+		set[strings.ToLower(name)] = true
+	}
+	added := false
+	for name, value := range options.DSNDefaults {
+		if !set[strings.ToLower(name)] {
+			query.Set(name, value)
+			added = true
+		}
+	}
+	if !added {
+		return rawQuery, nil
+	}
+	return query.Encode(), nil
+}
+
+func checkDSNOption(name string) error {
+	name = strings.ToLower(name)
+	if options.DataDir != "" && slices.Contains(sandboxOptions, name) {
+		return fmt.Errorf("%w: option %q cannot be set while the data directory is sandboxed", ErrDSNNotAllowed, name)
+	}
+	if slices.ContainsFunc(options.DeniedDSNOptions, func(denied string) bool { return strings.EqualFold(denied, name) }) {
+		return fmt.Errorf("%w: option %q is denied", ErrDSNNotAllowed, name)
+	}
+	if options.AllowedDSNOptions != nil &&
+		!slices.ContainsFunc(options.AllowedDSNOptions, func(allowed string) bool { return strings.EqualFold(allowed, name) }) {
+		return fmt.Errorf("%w: option %q is not allowed", ErrDSNNotAllowed, name)
+	}
+	return nil
+}
+
+// sandboxPath resolves the path of a file database against [Options.DataDir] and checks that it stays inside of it.
+func sandboxPath(path string) (string, error) {
+	root, err := filepath.Abs(options.DataDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the data directory: %w", err)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	path = filepath.Clean(path)
+
+	// Symlinks are resolved on both sides, the database file itself may not exist yet
+	realRoot, err := evalExistingSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the data directory: %w", err)
+	}
+	realPath, err := evalExistingSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the database path: %w", err)
+	}
+	if relative, err := filepath.Rel(realRoot, realPath); err != nil || relative == ".." ||
+		strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %q is outside of the data directory", ErrDSNNotAllowed, path)
+	}
+	return path, nil
+}
+
+// evalExistingSymlinks resolves the symlinks of the longest part of the path that exists.
+func evalExistingSymlinks(path string) (string, error) {
+	existing, missing := path, ""
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			return filepath.Join(resolved, missing), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return path, nil
+		}
+		missing = filepath.Join(filepath.Base(existing), missing)
+		existing = parent
+	}
+}
+
+func isInMemoryDSN(path string) bool {
+	return path == "" || strings.HasPrefix(path, ":memory:")
+}
+
+// dsnPath returns the path or alias of a DSN without its options. DSN options can hold credentials, so only the path
+// is logged.
+func dsnPath(dsn string) string {
+	path, _, _ := strings.Cut(dsn, "?")
+	return path
+}
+
+// databaseKey identifies the database a DSN points to, so DSNs that only differ by their options, by how the path is
+// spelled or by going through an alias share the same key.
+func databaseKey(dsn string) string {
+	if resolved, err := resolveDSN(dsn); err == nil {
+		dsn = resolved
+	}
+	path, _, _ := strings.Cut(dsn, "?")
+	if absolute, err := filepath.Abs(path); err == nil {
+		return absolute
+	}
+	return filepath.Clean(path)
+}
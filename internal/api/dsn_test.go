@@ -0,0 +1,158 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+	_ "github.com/duckdb/duckdb-go/v2"
+)
+
+func TestResolveDSN(t *testing.T) {
+	dataDir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dataDir, "escape")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+	t.Cleanup(func() { Configure(Options{}) })
+
+	t.Run("unrestricted by default", func(t *testing.T) {
+		Configure(Options{})
+		for _, dsn := range []string{"", ":memory:", "/tmp/duck.db", "duck.db?threads=4&allow_unsigned_extensions=true"} {
+			resolved, err := resolveDSN(dsn)
+			if err != nil || resolved != dsn {
+				t.Errorf("resolveDSN(%q): expected it unchanged, got %q (%v)", dsn, resolved, err)
+			}
+		}
+	})
+
+	t.Run("data directory", func(t *testing.T) {
+		Configure(Options{DataDir: dataDir})
+		for dsn, expected := range map[string]string{
+			"duck.db":                              filepath.Join(dataDir, "duck.db"),
+			"nested/../duck.db?threads=4":          filepath.Join(dataDir, "duck.db") + "?threads=4",
+			filepath.Join(dataDir, "other.db"):     filepath.Join(dataDir, "other.db"),
+			filepath.Join(dataDir, "new/dir/x.db"): filepath.Join(dataDir, "new/dir/x.db"),
+		} {
+			resolved, err := resolveDSN(dsn)
+			if err != nil || resolved != expected {
+				t.Errorf("resolveDSN(%q): expected %q, got %q (%v)", dsn, expected, resolved, err)
+			}
+		}
+
+		for _, dsn := range []string{
+			"../duck.db",
+			filepath.Join(outside, "duck.db"),
+			"escape/duck.db",
+			":memory:",
+			"",
+			"duck.db?enable_external_access=true",
+			"duck.db?allowed_directories=/",
+		} {
+			if _, err := resolveDSN(dsn); !errors.Is(err, ErrDSNNotAllowed) {
+				t.Errorf("resolveDSN(%q): expected the DSN to be rejected, got %v", dsn, err)
+			}
+		}
+
+		Configure(Options{DataDir: dataDir, AllowInMemory: true})
+		if _, err := resolveDSN(":memory:"); err != nil {
+			t.Errorf("expected in-memory databases to be allowed, got %v", err)
+		}
+	})
+
+	t.Run("options", func(t *testing.T) {
+		Configure(Options{AllowedDSNOptions: []string{"threads", "access_mode"}, DeniedDSNOptions: []string{"access_mode"}})
+		if _, err := resolveDSN("duck.db?THREADS=4"); err != nil {
+			t.Errorf("expected an allowed option to be accepted, got %v", err)
+		}
+		for _, dsn := range []string{"duck.db?access_mode=READ_ONLY", "duck.db?threads=4&memory_limit=1GB"} {
+			if _, err := resolveDSN(dsn); !errors.Is(err, ErrDSNNotAllowed) {
+				t.Errorf("resolveDSN(%q): expected the DSN to be rejected, got %v", dsn, err)
+			}
+		}
+	})
+
+	t.Run("aliases", func(t *testing.T) {
+		Configure(Options{
+			DataDir:           dataDir,
+			AllowedDSNOptions: []string{"threads"},
+			DSNAliases:        map[string]string{"analytics": "analytics.db?access_mode=READ_WRITE"},
+		})
+		resolved, err := resolveDSN("analytics?threads=2")
+		expected := filepath.Join(dataDir, "analytics.db") + "?access_mode=READ_WRITE&threads=2"
+		if err != nil || resolved != expected {
+			t.Errorf("expected %q, got %q (%v)", expected, resolved, err)
+		}
+		if _, err := resolveDSN("analytics?memory_limit=1GB"); !errors.Is(err, ErrDSNNotAllowed) {
+			t.Errorf("expected the options added to an alias to be checked, got %v", err)
+		}
+		if databaseKey("analytics") != databaseKey(filepath.Join(dataDir, "analytics.db")) {
+			t.Error("expected an alias to share the database key of its path")
+		}
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		Configure(Options{AllowedDSNOptions: []string{"threads"}, DSNDefaults: map[string]string{"threads": "4", "memory_limit": "1GB"}})
+		for dsn, expected := range map[string]string{
+			"duck.db":           "duck.db?memory_limit=1GB&threads=4",
+			"duck.db?THREADS=2": "duck.db?THREADS=2&memory_limit=1GB",
+		} {
+			resolved, err := resolveDSN(dsn)
+			if err != nil || resolved != expected {
+				t.Errorf("resolveDSN(%q): expected %q, got %q (%v)", dsn, expected, resolved, err)
+			}
+		}
+	})
+}
+
+func TestSandbox(t *testing.T) {
+	ctx := context.Background()
+	dataDir := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "outside.csv")
+	if err := os.WriteFile(outside, []byte("id\n1\n"), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	Configure(Options{DataDir: dataDir, JobDir: t.TempDir()})
+	t.Cleanup(func() { Configure(Options{}) })
+
+	dsn := "test_sandbox.db"
+	if _, err := Query(ctx, "../"+dsn, ducktape.QueryRequest{Query: "SELECT 1"}); !errors.Is(err, ErrDSNNotAllowed) {
+		t.Errorf("expected a DSN outside of the data directory to be rejected, got %v", err)
+	}
+
+	_, err := Execute(ctx, dsn, ducktape.ExecuteRequest{
+		Statements: []ducktape.ExecuteStatement{
+			{Query: `CREATE TABLE test_sandbox AS SELECT 1 AS id`},
+			{Query: `COPY test_sandbox TO ?`, Args: []any{filepath.Join(dataDir, "inside.csv")}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to write inside of the data directory: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, dsn)); err != nil {
+		t.Errorf("expected the database to be created in the data directory: %v", err)
+	}
+
+	_, err = Query(ctx, dsn, ducktape.QueryRequest{Query: "SELECT * FROM read_csv(?)", Args: []any{outside}})
+	if err == nil || !strings.Contains(err.Error(), "disabled by configuration") {
+		t.Errorf("expected reading a file outside of the data directory to fail, got %v", err)
+	}
+	_, err = Execute(ctx, dsn, ducktape.ExecuteRequest{
+		Statements: []ducktape.ExecuteStatement{{Query: `SET enable_external_access = true`}},
+	})
+	if err == nil {
+		t.Error("expected the sandbox settings to be locked")
+	}
+
+	job, err := SubmitJob(dsn, ducktape.QueryRequest{Query: "SELECT * FROM test_sandbox"}, 0)
+	if err != nil {
+		t.Fatalf("failed to submit job: %v", err)
+	}
+	if job = waitForJob(t, job.ID); job.State != ducktape.JobStateSucceeded {
+		t.Errorf("expected jobs to write their results, got %q (%v)", job.State, job.Error)
+	}
+}
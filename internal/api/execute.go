@@ -6,16 +6,16 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/artie-labs/ducktape/api/pkg/ducktape"
-	_ "github.com/duckdb/duckdb-go/v2"
 )
 
 func handleExecute(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 	dsn := r.Header.Get(ducktape.DuckDBConnectionStringHeader)
-	if dsn == "" {
+	if dsn == "" && r.Header.Get(ducktape.DuckDBTransactionIDHeader) == "" {
 		err := fmt.Errorf("%q header is required", ducktape.DuckDBConnectionStringHeader)
 		errMsg := err.Error()
 		handleBadRequestJSON(w, ducktape.QueryResponse{Error: &errMsg}, err)
@@ -25,15 +25,76 @@ func handleExecute(w http.ResponseWriter, r *http.Request) {
 	request, err := getRequestBody[ducktape.ExecuteRequest](r)
 	if err != nil {
 		errMsg := err.Error()
-		handleBadRequestJSON(w, ducktape.QueryResponse{Error: &errMsg}, err)
+		handleRequestBodyErrorJSON(w, ducktape.QueryResponse{Error: &errMsg}, err)
+		return
+	}
+	ctx, cancel, err := withQueryTimeout(r)
+	if err != nil {
+		errMsg := err.Error()
+		handleBadRequestJSON(w, ducktape.ExecuteResponse{Error: &errMsg}, err)
 		return
 	}
-	ctx := r.Context()
+	defer cancel()
 
-	result, err := Execute(ctx, dsn, request)
+	ctx, err = withReadOnlyHeader(ctx, r)
 	if err != nil {
 		errMsg := err.Error()
-		handleInternalServerErrorJSON(w, ducktape.ExecuteResponse{Error: &errMsg}, err)
+		handleBadRequestJSON(w, ducktape.ExecuteResponse{Error: &errMsg}, err)
+		return
+	}
+
+	ctx, operation := startOperation(ctx, w, r, "execute", dsn, strings.Join(statementQueries(request.Statements), ";\n"))
+	defer operations.finish(operation)
+
+	if request.DryRun {
+		if r.Header.Get(ducktape.DuckDBTransactionIDHeader) != "" {
+			err := fmt.Errorf("dry run is not supported inside a transaction")
+			errMsg := err.Error()
+			handleBadRequestJSON(w, ducktape.ExecuteResponse{Error: &errMsg}, err)
+			return
+		}
+
+		validation, err := Validate(ctx, dsn, request.Statements)
+		if err != nil {
+			err = contextError(ctx, err)
+			errMsg := err.Error()
+			handleErrorJSON(w, ducktape.ExecuteResponse{Error: &errMsg}, err)
+			return
+		}
+
+		body, err := json.Marshal(ducktape.ExecuteResponse{Validation: validation})
+		if err != nil {
+			err := fmt.Errorf("failed to marshal the response: %v", err)
+			errMsg := err.Error()
+			handleInternalServerErrorJSON(w, ducktape.ExecuteResponse{Error: &errMsg}, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+		LoggerFromContext(r.Context()).Debug("dry run results", slog.Any("validation", validation), slog.Duration("elapsed", time.Since(start)))
+		return
+	}
+
+	idempotencyKey := r.Header.Get(ducktape.IdempotencyKeyHeader)
+
+	var result sql.Result
+	var replayed bool
+	if transactionID := r.Header.Get(ducktape.DuckDBTransactionIDHeader); transactionID != "" {
+		if idempotencyKey != "" {
+			err := fmt.Errorf("%q header is not supported inside a transaction", ducktape.IdempotencyKeyHeader)
+			errMsg := err.Error()
+			handleBadRequestJSON(w, ducktape.ExecuteResponse{Error: &errMsg}, err)
+			return
+		}
+		result, err = ExecuteInTransaction(ctx, transactionID, dsn, request)
+	} else {
+		result, replayed, err = ExecuteIdempotent(ctx, dsn, idempotencyKey, request)
+	}
+	if err != nil {
+		err = contextError(ctx, err)
+		errMsg := err.Error()
+		handleErrorJSON(w, ducktape.ExecuteResponse{Error: &errMsg}, err)
 		return
 	}
 
@@ -48,6 +109,9 @@ func handleExecute(w http.ResponseWriter, r *http.Request) {
 	response := ducktape.ExecuteResponse{
 		RowsAffectedCount: rowsAffected,
 	}
+	if executeResponse, ok := result.(ducktape.ExecuteResponse); ok {
+		response.SkippedStatements = executeResponse.SkippedStatements
+	}
 	body, err := json.Marshal(response)
 	if err != nil {
 		err := fmt.Errorf("failed to marshal the response: %v", err)
@@ -55,51 +119,141 @@ func handleExecute(w http.ResponseWriter, r *http.Request) {
 		handleInternalServerErrorJSON(w, ducktape.ExecuteResponse{Error: &errMsg}, err)
 	}
 	w.Header().Set("Content-Type", "application/json")
+	if replayed {
+		w.Header().Set(ducktape.IdempotentReplayedHeader, "true")
+	}
 	w.WriteHeader(http.StatusOK)
 	w.Write(body)
-	slog.Debug("execution results", slog.Any("rows affected", rowsAffected), slog.Duration("elapsed", time.Since(start)))
+	LoggerFromContext(r.Context()).Debug("execution results", slog.Any("rows affected", rowsAffected), slog.Duration("elapsed", time.Since(start)))
 }
 
 func Execute(ctx context.Context, dsn string, request ducktape.ExecuteRequest) (sql.Result, error) {
+	result, _, err := ExecuteIdempotent(ctx, dsn, "", request)
+	return result, err
+}
+
+// ExecuteIdempotent runs the statements like [Execute]. When idempotencyKey is set, the response is persisted in the same
+// transaction as the statements, and later calls with the same key return it without running the statements again.
+func ExecuteIdempotent(ctx context.Context, dsn string, idempotencyKey string, request ducktape.ExecuteRequest) (result sql.Result, replayed bool, err error) {
 	if len(request.Statements) == 0 {
-		return nil, fmt.Errorf("at least one statement is required")
+		return nil, false, fmt.Errorf("at least one statement is required")
+	}
+	if err := checkReadOnly(ctx, dsn, statementQueries(request.Statements)...); err != nil {
+		return nil, false, err
 	}
+	// Runs last, once the transaction has been committed or rolled back
+	defer results.invalidate(dsn)
 
-	db, err := sql.Open("duckdb", dsn)
+	db, err := openDB(ctx, dsn, "execute")
 	if err != nil {
-		return nil, fmt.Errorf("failed to start a SQL client for execute(%q): %w", "duckdb", err)
+		return nil, false, err
 	}
 	defer db.Close()
 
-	if err = db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to validate the DB connection for execute(%q): %w", "duckdb", err)
-	}
-
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to begin a transaction for execute(%q): %w", "duckdb", err)
+		return nil, false, fmt.Errorf("failed to begin a transaction for execute(%q): %w", "duckdb", err)
 	}
 	defer tx.Rollback()
 
-	var totalRowsAffected int64
+	var requestHash string
+	if idempotencyKey != "" {
+		body, err := json.Marshal(request)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to marshal the request: %w", err)
+		}
+		h := newRequestHash(ducktape.ExecuteRoute)
+		h.Write(body)
+		requestHash = requestHashString(h)
+
+		stored, storedHash, found, err := lookupIdempotencyKey(ctx, tx, idempotencyTable, ducktape.ExecuteRoute, idempotencyKey)
+		if err != nil {
+			return nil, false, err
+		}
+		if found {
+			if err := checkRequestHash(storedHash, requestHash); err != nil {
+				return nil, false, err
+			}
+			var response ducktape.ExecuteResponse
+			if err := json.Unmarshal(stored, &response); err != nil {
+				return nil, false, fmt.Errorf("failed to unmarshal the stored response: %w", err)
+			}
+			return response, true, nil
+		}
+	}
+
+	response, err := executeStatements(ctx, tx, request)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if idempotencyKey != "" {
+		stored, err := json.Marshal(response)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to marshal the response: %w", err)
+		}
+		if err := storeIdempotencyKey(ctx, tx, idempotencyTable, ducktape.ExecuteRoute, idempotencyKey, requestHash, stored); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		if idempotencyKey != "" {
+			err = idempotencyConflict(err)
+		}
+		return nil, false, fmt.Errorf("failed to commit the transaction: %w", err)
+	}
+	return response, false, nil
+}
 
-	for _, statement := range request.Statements {
+func statementQueries(statements []ducktape.ExecuteStatement) []string {
+	queries := make([]string, len(statements))
+	for i, statement := range statements {
+		queries[i] = statement.Query
+	}
+	return queries
+}
 
-		slog.Debug("executing duckdb query", slog.String("query", statement.Query), slog.Any("args", statement.Args))
+func executeStatements(ctx context.Context, tx *sql.Tx, request ducktape.ExecuteRequest) (ducktape.ExecuteResponse, error) {
+	switch request.ErrorMode {
+	case "", ducktape.ErrorModeAbort, ducktape.ErrorModeContinue:
+	default:
+		return ducktape.ExecuteResponse{}, fmt.Errorf("%w: unsupported error mode %q", ErrInvalidRequest, request.ErrorMode)
+	}
+
+	var response ducktape.ExecuteResponse
+	for i, statement := range request.Statements {
 
-		result, err := tx.ExecContext(ctx, statement.Query, statement.Args...)
+		LoggerFromContext(ctx).Debug("executing duckdb query", slog.String("query", statement.Query), slog.Any("args", statement.Args), slog.Any("namedArgs", statement.NamedArgs))
+
+		query, args, err := bindArgs(statement.Query, statement.Args, statement.NamedArgs)
 		if err != nil {
-			return nil, fmt.Errorf("failed to execute the query: %w", err)
+			return ducktape.ExecuteResponse{}, fmt.Errorf("failed to bind the arguments of statement %d: %w", i, err)
+		}
+		statementCtx, span := startSpan(ctx, "duckdb.execute", queryText(statement.Query))
+		result, err := tx.ExecContext(statementCtx, query, args...)
+		endSpan(span, err)
+		if err != nil {
+			if !statement.Optional && request.ErrorMode != ducktape.ErrorModeContinue {
+				return ducktape.ExecuteResponse{}, fmt.Errorf("failed to execute the query: %w", err)
+			}
+			// DuckDB has no savepoints, so a failed statement can only be skipped when it did not abort the transaction.
+			// Catalog and binder errors (e.g. "already exists") leave the transaction usable, execution errors do not.
+			if _, probeErr := tx.ExecContext(ctx, "SELECT 1"); probeErr != nil {
+				return ducktape.ExecuteResponse{}, fmt.Errorf("failed to execute optional statement %d, the transaction was aborted: %w", i, err)
+			}
+			LoggerFromContext(ctx).Debug("skipping failed optional statement", slog.Int("index", i), slog.Any("error", err))
+			response.SkippedStatements = append(response.SkippedStatements, ducktape.SkippedStatement{Index: i, Error: err.Error()})
+			continue
 		}
 
 		rowsAffected, err := result.RowsAffected()
 		if err != nil {
-			return nil, fmt.Errorf("failed to get the rows affected: %v", err)
+			return ducktape.ExecuteResponse{}, fmt.Errorf("failed to get the rows affected: %v", err)
 		}
-		totalRowsAffected += rowsAffected
-	}
-	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("failed to commit the transaction: %w", err)
+		response.RowsAffectedCount += rowsAffected
+		operationFromContext(ctx).addRows(rowsAffected)
+		rowsAffectedTotal.Add(float64(rowsAffected))
 	}
-	return ducktape.ExecuteResponse{RowsAffectedCount: totalRowsAffected}, nil
+	return response, nil
 }
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"errors"
 	"os"
 	"strings"
 	"testing"
@@ -336,4 +337,90 @@ func TestExecute(t *testing.T) {
 			t.Errorf("expected 0 rows (transaction rolled back), got %d", len(rows))
 		}
 	})
+
+	t.Run("optional statement that already exists is skipped", func(t *testing.T) {
+		dsn := "test_execute_optional.db"
+		t.Cleanup(func() { os.Remove(dsn) })
+
+		_, err := Execute(ctx, dsn, ducktape.ExecuteRequest{
+			Statements: []ducktape.ExecuteStatement{
+				{Query: `CREATE TABLE test_optional (id INTEGER)`},
+				{Query: `CREATE INDEX test_optional_idx ON test_optional (id)`},
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to create table: %v", err)
+		}
+
+		result, err := Execute(ctx, dsn, ducktape.ExecuteRequest{
+			Statements: []ducktape.ExecuteStatement{
+				{Query: `CREATE INDEX test_optional_idx ON test_optional (id)`, Optional: true},
+				{Query: `INSERT INTO test_optional VALUES (1), (2)`},
+			},
+		})
+		if err != nil {
+			t.Fatalf("expected the batch to succeed, got: %v", err)
+		}
+
+		response, ok := result.(ducktape.ExecuteResponse)
+		if !ok {
+			t.Fatalf("expected ducktape.ExecuteResponse, got %T", result)
+		}
+		if response.RowsAffectedCount != 2 {
+			t.Errorf("expected 2 rows affected, got %d", response.RowsAffectedCount)
+		}
+		if len(response.SkippedStatements) != 1 || response.SkippedStatements[0].Index != 0 {
+			t.Errorf("expected statement 0 to be skipped, got %v", response.SkippedStatements)
+		}
+		if len(response.SkippedStatements) == 1 && !strings.Contains(response.SkippedStatements[0].Error, "already exists") {
+			t.Errorf("expected an already exists error, got %q", response.SkippedStatements[0].Error)
+		}
+	})
+
+	t.Run("continue mode fails when the transaction is aborted", func(t *testing.T) {
+		dsn := "test_execute_continue_aborted.db"
+		t.Cleanup(func() { os.Remove(dsn) })
+
+		_, err := Execute(ctx, dsn, ducktape.ExecuteRequest{
+			Statements: []ducktape.ExecuteStatement{
+				{Query: `CREATE TABLE test_continue_aborted (id INTEGER)`},
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to create table: %v", err)
+		}
+
+		// A conversion error happens during execution and aborts the DuckDB transaction
+		_, err = Execute(ctx, dsn, ducktape.ExecuteRequest{
+			ErrorMode: ducktape.ErrorModeContinue,
+			Statements: []ducktape.ExecuteStatement{
+				{Query: `INSERT INTO test_continue_aborted VALUES (1)`},
+				{Query: `INSERT INTO test_continue_aborted VALUES ('not a number')`},
+			},
+		})
+		if err == nil {
+			t.Fatal("expected error for aborted transaction, got none")
+		}
+		if !strings.Contains(err.Error(), "transaction was aborted") {
+			t.Errorf("expected error to mention the aborted transaction, got: %v", err)
+		}
+
+		rows, err := Query(ctx, dsn, ducktape.QueryRequest{Query: "SELECT * FROM test_continue_aborted"})
+		if err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+		if len(rows) != 0 {
+			t.Errorf("expected 0 rows (transaction rolled back), got %d", len(rows))
+		}
+	})
+
+	t.Run("unsupported error mode", func(t *testing.T) {
+		_, err := Execute(ctx, "", ducktape.ExecuteRequest{
+			ErrorMode:  "ignore",
+			Statements: []ducktape.ExecuteStatement{{Query: "SELECT 1"}},
+		})
+		if !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("expected an invalid request error for unsupported error mode, got %v", err)
+		}
+	})
 }
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+	"github.com/duckdb/duckdb-go/v2"
+)
+
+func handleExplain(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	dsn := r.Header.Get(ducktape.DuckDBConnectionStringHeader)
+	if dsn == "" {
+		err := fmt.Errorf("%q header is required", ducktape.DuckDBConnectionStringHeader)
+		errMsg := err.Error()
+		handleBadRequestJSON(w, ducktape.ExplainResponse{Error: &errMsg}, err)
+		return
+	}
+
+	request, err := getRequestBody[ducktape.ExplainRequest](r)
+	if err != nil {
+		errMsg := err.Error()
+		handleRequestBodyErrorJSON(w, ducktape.ExplainResponse{Error: &errMsg}, err)
+		return
+	}
+	ctx, cancel, err := withQueryTimeout(r)
+	if err != nil {
+		errMsg := err.Error()
+		handleBadRequestJSON(w, ducktape.ExplainResponse{Error: &errMsg}, err)
+		return
+	}
+	defer cancel()
+
+	ctx, err = withReadOnlyHeader(ctx, r)
+	if err != nil {
+		errMsg := err.Error()
+		handleBadRequestJSON(w, ducktape.ExplainResponse{Error: &errMsg}, err)
+		return
+	}
+
+	plan, err := Explain(ctx, dsn, request)
+	if err != nil {
+		err = contextError(ctx, err)
+		errMsg := err.Error()
+		handleErrorJSON(w, ducktape.ExplainResponse{Error: &errMsg}, err)
+		return
+	}
+
+	body, err := json.Marshal(ducktape.ExplainResponse{Plan: plan})
+	if err != nil {
+		err := fmt.Errorf("failed to marshal the response: %v", err)
+		errMsg := err.Error()
+		handleInternalServerErrorJSON(w, ducktape.ExplainResponse{Error: &errMsg}, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+	LoggerFromContext(r.Context()).Debug("explain results", slog.Bool("analyze", request.Analyze), slog.Duration("elapsed", time.Since(start)))
+}
+
+// Explain returns DuckDB's JSON plan for the query. In analyze mode the query is run, inside a transaction that is
+// always rolled back, and the JSON profiling output is returned instead.
+func Explain(ctx context.Context, dsn string, request ducktape.ExplainRequest) ([]byte, error) {
+	if request.Query == "" {
+		return nil, fmt.Errorf("query is required")
+	}
+	// Analyze runs the query, the transaction being rolled back does not undo everything (e.g. COPY TO a file)
+	if err := checkReadOnly(ctx, dsn, request.Query); err != nil {
+		return nil, err
+	}
+	query, args, err := bindArgs(request.Query, request.Args, request.NamedArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := openDB(ctx, dsn, "explain")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get a connection for explain(%q): %w", "duckdb", err)
+	}
+	defer conn.Close()
+
+	if err := checkSingleStatement(ctx, conn, query); err != nil {
+		return nil, err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin a transaction for explain(%q): %w", "duckdb", err)
+	}
+	defer tx.Rollback()
+
+	options := "FORMAT json"
+	if request.Analyze {
+		options = "ANALYZE, FORMAT json"
+	}
+
+	LoggerFromContext(ctx).Debug("explaining duckdb query", slog.String("query", request.Query), slog.Any("args", request.Args), slog.Any("namedArgs", request.NamedArgs), slog.Bool("analyze", request.Analyze))
+
+	explainCtx, span := startSpan(ctx, "duckdb.explain", queryText(request.Query))
+	rows, err := tx.QueryContext(explainCtx, fmt.Sprintf("EXPLAIN (%s) %s", options, query), args...)
+	endSpan(span, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to explain the query: %w", err)
+	}
+	defer rows.Close()
+
+	var plan string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key, &plan); err != nil {
+			return nil, fmt.Errorf("failed to scan the query plan: %w", err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate over the query plan: %w", err)
+	}
+	if plan == "" {
+		return nil, fmt.Errorf("DuckDB did not return a query plan")
+	}
+	if !json.Valid([]byte(plan)) {
+		return nil, fmt.Errorf("DuckDB returned a query plan that is not valid JSON")
+	}
+	return []byte(plan), nil
+}
+
+// checkSingleStatement returns an error unless the query is exactly one statement. The driver runs every statement of
+// a query but the last one, so "SELECT 1; COMMIT; DROP TABLE t" would commit and drop the table before the EXPLAIN,
+// which only wraps the first statement, is even planned. SELECT statements are counted by json_serialize_sql, the
+// others, which it cannot serialize, are prepared with Prepare, which refuses several statements without running any.
+func checkSingleStatement(ctx context.Context, conn *sql.Conn, query string) error {
+	statements, err := serializeStatements(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to classify the statement: %w", err)
+	}
+	if !statements.Error {
+		if len(statements.Statements) != 1 {
+			return fmt.Errorf("%w: exactly one statement can be explained, got %d", ErrInvalidRequest, len(statements.Statements))
+		}
+		return nil
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		driverStmt, err := driverConn.(*duckdb.Conn).Prepare(query)
+		if err != nil {
+			return err
+		}
+		return driverStmt.Close()
+	})
+	if err != nil {
+		return fmt.Errorf("%w: failed to prepare the statement to explain: %w", ErrInvalidRequest, err)
+	}
+	return nil
+}
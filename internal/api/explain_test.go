@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+	_ "github.com/duckdb/duckdb-go/v2"
+)
+
+func TestExplain(t *testing.T) {
+	ctx := context.Background()
+	dsn := "test_explain.db"
+	t.Cleanup(func() { os.Remove(dsn) })
+
+	_, err := Execute(ctx, dsn, ducktape.ExecuteRequest{
+		Statements: []ducktape.ExecuteStatement{
+			{Query: `CREATE TABLE test_explain AS SELECT range AS id FROM range(100)`},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	t.Run("physical plan", func(t *testing.T) {
+		plan, err := Explain(ctx, dsn, ducktape.ExplainRequest{
+			Query: "SELECT sum(id) FROM test_explain WHERE id > ?",
+			Args:  []any{10},
+		})
+		if err != nil {
+			t.Fatalf("failed to explain: %v", err)
+		}
+
+		var operators []map[string]any
+		if err := json.Unmarshal(plan, &operators); err != nil {
+			t.Fatalf("failed to unmarshal plan: %v", err)
+		}
+		if len(operators) == 0 || operators[0]["name"] == nil {
+			t.Errorf("expected a plan with named operators, got %s", plan)
+		}
+	})
+
+	t.Run("analyze returns operator profiles", func(t *testing.T) {
+		plan, err := Explain(ctx, dsn, ducktape.ExplainRequest{
+			Query:   "SELECT sum(id) FROM test_explain WHERE id > ?",
+			Args:    []any{10},
+			Analyze: true,
+		})
+		if err != nil {
+			t.Fatalf("failed to explain analyze: %v", err)
+		}
+
+		var profile map[string]any
+		if err := json.Unmarshal(plan, &profile); err != nil {
+			t.Fatalf("failed to unmarshal profile: %v", err)
+		}
+		if _, ok := profile["latency"]; !ok {
+			t.Errorf("expected the profile to contain latency, got %s", plan)
+		}
+		if children, ok := profile["children"].([]any); !ok || len(children) == 0 {
+			t.Errorf("expected the profile to contain operators, got %s", plan)
+		}
+	})
+
+	t.Run("analyze does not commit writes", func(t *testing.T) {
+		_, err := Explain(ctx, dsn, ducktape.ExplainRequest{
+			Query:   "DELETE FROM test_explain",
+			Analyze: true,
+		})
+		if err != nil {
+			t.Fatalf("failed to explain analyze: %v", err)
+		}
+
+		rows, err := Query(ctx, dsn, ducktape.QueryRequest{Query: "SELECT * FROM test_explain"})
+		if err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+		if len(rows) != 100 {
+			t.Errorf("expected 100 rows after explain analyze, got %d", len(rows))
+		}
+	})
+
+	t.Run("multiple statements are rejected", func(t *testing.T) {
+		for _, query := range []string{
+			"SELECT 1; COMMIT; DROP TABLE test_explain",
+			"DELETE FROM test_explain; COMMIT; DROP TABLE test_explain",
+		} {
+			for _, ctx := range []context.Context{ctx, WithReadOnly(ctx)} {
+				_, err := Explain(ctx, dsn, ducktape.ExplainRequest{Query: query})
+				if err == nil {
+					t.Errorf("expected an error for %q, got none", query)
+				}
+			}
+		}
+
+		rows, err := Query(ctx, dsn, ducktape.QueryRequest{Query: "SELECT count(*) AS n FROM test_explain"})
+		if err != nil {
+			t.Fatalf("failed to count rows: %v", err)
+		}
+		if rows[0]["n"] != int64(100) {
+			t.Errorf("expected the table to keep its 100 rows, got %v", rows[0]["n"])
+		}
+	})
+
+	t.Run("writes are rejected in read-only mode", func(t *testing.T) {
+		_, err := Explain(WithReadOnly(ctx), dsn, ducktape.ExplainRequest{Query: "DROP TABLE test_explain"})
+		if !errors.Is(err, ErrReadOnly) {
+			t.Errorf("expected a read-only error, got %v", err)
+		}
+	})
+
+	t.Run("invalid SQL", func(t *testing.T) {
+		_, err := Explain(ctx, dsn, ducktape.ExplainRequest{Query: "INVALID SQL"})
+		if err == nil {
+			t.Error("expected error for invalid SQL, got none")
+		}
+	})
+}
@@ -0,0 +1,285 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+)
+
+const (
+	defaultHealthCheckTimeout = 5 * time.Second
+	// readinessCacheInterval is how long the outcome of the readiness checks is reused, so that frequent probes do not
+	// open every database and write to the data directory each time.
+	readinessCacheInterval = time.Second
+)
+
+// draining is set once the server is about to shut down, readiness probes fail from then on while requests are still
+// served.
+var draining atomic.Bool
+
+// Drain makes readiness probes fail, so that load balancers stop routing requests to the server before it stops
+// accepting connections. [Shutdown] drains the server as well.
+func Drain() {
+	draining.Store(true)
+	readiness.reset()
+}
+
+// healthCheck is one of the checks behind [ducktape.ReadinessRoute]. run returns a description of what it checked.
+type healthCheck struct {
+	name string
+	run  func(ctx context.Context) (string, error)
+}
+
+// readinessChecks returns the checks of the server's dependencies: its drain state, every configured database and the
+// directory that file databases are created in.
+func readinessChecks() []healthCheck {
+	checks := []healthCheck{{name: "drain", run: checkDrain}}
+
+	dsns := slices.Sorted(maps.Keys(options.DSNAliases))
+	for _, dsn := range options.HealthCheckDSNs {
+		if _, ok := options.DSNAliases[dsn]; !ok {
+			dsns = append(dsns, dsn)
+		}
+	}
+	for _, dsn := range dsns {
+		// DSN options may hold secrets, they are left out of the name like they are left out of the logs
+		checks = append(checks, healthCheck{name: "dsn:" + dsnPath(dsn), run: func(ctx context.Context) (string, error) {
+			return checkDSN(ctx, dsn)
+		}})
+	}
+
+	// Relative DSN paths are resolved against the working directory when there is no data directory
+	dir := options.DataDir
+	if dir == "" {
+		dir = "."
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	checks = append(checks, healthCheck{name: "disk", run: func(context.Context) (string, error) {
+		return checkDisk(dir, options.HealthMinFreeBytes)
+	}})
+	return checks
+}
+
+func checkDrain(context.Context) (string, error) {
+	if draining.Load() || shuttingDown.Load() {
+		return "", ErrShuttingDown
+	}
+	return "accepting requests", nil
+}
+
+// checkDSN opens the database and queries it. Opening a database that does not exist yet would create its file, so
+// for those it only checks that the directory the file would be created in exists.
+func checkDSN(ctx context.Context, dsn string) (string, error) {
+	resolved, err := resolveDSN(dsn)
+	if err != nil {
+		return "", err
+	}
+	if path := dsnPath(resolved); !isInMemoryDSN(path) {
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			if _, err := os.Stat(filepath.Dir(path)); err != nil {
+				return "", fmt.Errorf("failed to find the directory of the database: %w", err)
+			}
+			return "not created yet", nil
+		} else if err != nil {
+			return "", fmt.Errorf("failed to find the database: %w", err)
+		}
+	}
+
+	db, err := openDB(ctx, dsn, "readiness")
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	var version string
+	if err := db.QueryRowContext(ctx, "SELECT version()").Scan(&version); err != nil {
+		return "", fmt.Errorf("failed to query the database: %w", err)
+	}
+	return "DuckDB " + version, nil
+}
+
+// checkDisk checks that a file can be created in the directory and that its file system has at least minFreeBytes
+// available.
+func checkDisk(dir string, minFreeBytes int64) (string, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return "", fmt.Errorf("failed to get the free space of %q: %w", dir, err)
+	}
+	free := stat.Bavail * uint64(stat.Bsize)
+	detail := fmt.Sprintf("%d bytes free in %s", free, dir)
+
+	f, err := os.CreateTemp(dir, ".ducktape-health-*")
+	if err != nil {
+		return detail, fmt.Errorf("directory is not writable: %w", err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	if minFreeBytes > 0 && free < uint64(minFreeBytes) {
+		return detail, fmt.Errorf("%d bytes free, at least %d are required", free, minFreeBytes)
+	}
+	return detail, nil
+}
+
+// runHealthChecks runs the checks concurrently. A check that does not return before the context is done fails, its
+// goroutine is left to finish in the background.
+func runHealthChecks(ctx context.Context, checks []healthCheck) ducktape.HealthResponse {
+	response := ducktape.HealthResponse{Status: ducktape.HealthStatusOK, Checks: make([]ducktape.HealthCheck, len(checks))}
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Go(func() {
+			type outcome struct {
+				detail string
+				err    error
+			}
+			done := make(chan outcome, 1)
+			go func() {
+				detail, err := check.run(ctx)
+				done <- outcome{detail, err}
+			}()
+
+			var result outcome
+			select {
+			case result = <-done:
+			case <-ctx.Done():
+				result.err = fmt.Errorf("check did not complete: %w", ctx.Err())
+			}
+
+			response.Checks[i] = ducktape.HealthCheck{Name: check.name, Status: ducktape.HealthStatusOK, Detail: result.detail}
+			if result.err != nil {
+				errMsg := result.err.Error()
+				response.Checks[i].Status = ducktape.HealthStatusFail
+				response.Checks[i].Error = &errMsg
+			}
+		})
+	}
+	wg.Wait()
+
+	for _, check := range response.Checks {
+		if check.Status != ducktape.HealthStatusOK {
+			response.Status = ducktape.HealthStatusFail
+		}
+	}
+	return response
+}
+
+// Readiness checks whether the server can serve requests, see [Options.HealthCheckDSNs] for what it checks.
+func Readiness(ctx context.Context) ducktape.HealthResponse {
+	timeout := options.HealthCheckTimeout
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return runHealthChecks(ctx, readinessChecks())
+}
+
+// readinessCache holds the outcome of the last readiness checks for [readinessCacheInterval]. Probes that arrive while
+// the checks run wait for their outcome instead of running them again.
+type readinessCache struct {
+	mu        sync.Mutex
+	response  ducktape.HealthResponse
+	checkedAt time.Time
+}
+
+var readiness = &readinessCache{}
+
+func (c *readinessCache) get(ctx context.Context) ducktape.HealthResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.checkedAt.IsZero() && time.Since(c.checkedAt) < readinessCacheInterval {
+		return c.response
+	}
+
+	// The outcome is shared with other probes, so it must not fail because this one went away
+	c.response = Readiness(context.WithoutCancel(ctx))
+	c.checkedAt = time.Now()
+	for _, check := range c.response.Checks {
+		if check.Error != nil && check.Name != "drain" {
+			slog.Warn("readiness check failed", slog.String("check", check.Name), slog.String("error", *check.Error))
+		}
+	}
+	return c.response
+}
+
+// reset discards the cached outcome, so that the next probe reflects a change of the drain state right away.
+func (c *readinessCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checkedAt = time.Time{}
+}
+
+// handleLiveness only reports that the process serves requests, so that an orchestrator restarts it when it does not.
+// The server's dependencies are checked by readiness instead, a restart would not fix them.
+func handleLiveness(w http.ResponseWriter, r *http.Request) {
+	writeHealthResponse(w, ducktape.HealthResponse{Status: ducktape.HealthStatusOK, Checks: []ducktape.HealthCheck{}})
+}
+
+// handleReadiness serves the cached readiness checks. The route is not behind the API's authentication, so only callers
+// that one of the authenticators accepts get the details and errors of the checks, others only get their status. The
+// errors are logged either way. Without any authenticator, every caller gets the details, like every caller can use the
+// API.
+func handleReadiness(authenticators []Authenticator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response := readiness.get(r.Context())
+		if !isAuthenticated(r, authenticators) {
+			response = healthStatuses(response)
+		}
+		writeHealthResponse(w, response)
+	}
+}
+
+// isAuthenticated reports whether one of the authenticators accepts the request, or there is no authenticator at all.
+func isAuthenticated(r *http.Request, authenticators []Authenticator) bool {
+	if len(authenticators) == 0 {
+		return true
+	}
+	for _, authenticator := range authenticators {
+		if _, err := authenticator.Authenticate(r); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// healthStatuses returns the response without the details and errors of its checks.
+func healthStatuses(response ducktape.HealthResponse) ducktape.HealthResponse {
+	statuses := ducktape.HealthResponse{Status: response.Status, Checks: make([]ducktape.HealthCheck, len(response.Checks))}
+	for i, check := range response.Checks {
+		statuses.Checks[i] = ducktape.HealthCheck{Name: check.Name, Status: check.Status}
+	}
+	return statuses
+}
+
+func writeHealthResponse(w http.ResponseWriter, response ducktape.HealthResponse) {
+	body, err := json.Marshal(response)
+	if err != nil {
+		err := fmt.Errorf("failed to marshal the response: %v", err)
+		errMsg := err.Error()
+		handleInternalServerErrorJSON(w, ducktape.ErrorResponse{Error: &errMsg}, err)
+		return
+	}
+	status := http.StatusOK
+	if response.Status != ducktape.HealthStatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(body)
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+	_ "github.com/duckdb/duckdb-go/v2"
+)
+
+func TestHealth(t *testing.T) {
+	dataDir := t.TempDir()
+	t.Cleanup(func() {
+		draining.Store(false)
+		readiness.reset()
+		Configure(Options{})
+	})
+
+	mux := http.NewServeMux()
+	RegisterHealthCheckRoutes(mux)
+	probe := func(route string) (int, ducktape.HealthResponse) {
+		// Every probe checks the current options
+		readiness.reset()
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", route, nil))
+		var response ducktape.HealthResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to unmarshal %q: %v", w.Body.String(), err)
+		}
+		return w.Code, response
+	}
+	checks := func(response ducktape.HealthResponse) map[string]ducktape.HealthCheck {
+		result := make(map[string]ducktape.HealthCheck)
+		for _, check := range response.Checks {
+			result[check.Name] = check
+		}
+		return result
+	}
+
+	t.Run("liveness", func(t *testing.T) {
+		Configure(Options{HealthMinFreeBytes: 1 << 62})
+		if code, response := probe(ducktape.LivenessRoute); code != http.StatusOK || response.Status != ducktape.HealthStatusOK {
+			t.Errorf("expected the server to be live regardless of its dependencies, got %d %+v", code, response)
+		}
+	})
+
+	t.Run("ready", func(t *testing.T) {
+		Configure(Options{DataDir: dataDir, DSNAliases: map[string]string{"analytics": "analytics.db"}, HealthCheckDSNs: []string{"events.db?threads=1"}})
+		if _, err := Execute(context.Background(), "analytics", ducktape.ExecuteRequest{Statements: []ducktape.ExecuteStatement{{Query: "CREATE TABLE t (id INTEGER)"}}}); err != nil {
+			t.Fatalf("failed to create the database: %v", err)
+		}
+		code, response := probe(ducktape.ReadinessRoute)
+		if code != http.StatusOK || response.Status != ducktape.HealthStatusOK {
+			t.Fatalf("expected the server to be ready, got %d %+v", code, response)
+		}
+		recorded := checks(response)
+		for _, name := range []string{"drain", "dsn:analytics", "dsn:events.db", "disk"} {
+			if check, ok := recorded[name]; !ok || check.Status != ducktape.HealthStatusOK {
+				t.Errorf("expected the %q check to pass, got %+v", name, response.Checks)
+			}
+		}
+		if detail := recorded["dsn:analytics"].Detail; !strings.HasPrefix(detail, "DuckDB") {
+			t.Errorf("expected the existing database to be queried, got %q", detail)
+		}
+		if _, err := os.Stat(filepath.Join(dataDir, "events.db")); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected the check not to create the database, got %v", err)
+		}
+	})
+
+	t.Run("unavailable database", func(t *testing.T) {
+		Configure(Options{DataDir: dataDir, DSNAliases: map[string]string{"analytics": filepath.Join("missing", "analytics.db")}})
+		code, response := probe(ducktape.ReadinessRoute)
+		if code != http.StatusServiceUnavailable || response.Status != ducktape.HealthStatusFail {
+			t.Fatalf("expected the server not to be ready, got %d %+v", code, response)
+		}
+		recorded := checks(response)
+		if check := recorded["dsn:analytics"]; check.Status != ducktape.HealthStatusFail || check.Error == nil {
+			t.Errorf("expected the database check to fail, got %+v", check)
+		}
+		if check := recorded["disk"]; check.Status != ducktape.HealthStatusOK {
+			t.Errorf("expected the other checks to pass, got %+v", check)
+		}
+	})
+
+	t.Run("low disk space", func(t *testing.T) {
+		Configure(Options{DataDir: dataDir, HealthMinFreeBytes: 1 << 62})
+		code, response := probe(ducktape.ReadinessRoute)
+		if check := checks(response)["disk"]; code != http.StatusServiceUnavailable || check.Error == nil || check.Detail == "" {
+			t.Errorf("expected the disk check to fail with the free space, got %d %+v", code, check)
+		}
+	})
+
+	t.Run("draining", func(t *testing.T) {
+		Configure(Options{DataDir: dataDir})
+		Drain()
+		code, response := probe(ducktape.ReadinessRoute)
+		if check := checks(response)["drain"]; code != http.StatusServiceUnavailable || check.Status != ducktape.HealthStatusFail {
+			t.Errorf("expected the server not to be ready while it drains, got %d %+v", code, check)
+		}
+		if code, _ := probe(ducktape.LivenessRoute); code != http.StatusOK {
+			t.Errorf("expected the server to stay live while it drains, got %d", code)
+		}
+	})
+
+	t.Run("results are cached", func(t *testing.T) {
+		draining.Store(false)
+		Configure(Options{DataDir: dataDir})
+		if code, _ := probe(ducktape.ReadinessRoute); code != http.StatusOK {
+			t.Fatalf("expected the server to be ready, got %d", code)
+		}
+
+		Configure(Options{DataDir: dataDir, HealthMinFreeBytes: 1 << 62})
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", ducktape.ReadinessRoute, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("expected the cached outcome within the interval, got %d", w.Code)
+		}
+	})
+
+	t.Run("details are only returned to authenticated callers", func(t *testing.T) {
+		Configure(Options{DataDir: dataDir, HealthMinFreeBytes: 1 << 62})
+		authenticated := http.NewServeMux()
+		RegisterHealthCheckRoutes(authenticated, NewAPIKeyAuthenticator(map[string]string{"key-1": "ops"}))
+		readiness.reset()
+
+		for key, expectDetails := range map[string]bool{"": false, "wrong-key": false, "key-1": true} {
+			r := httptest.NewRequest("GET", ducktape.ReadinessRoute, nil)
+			if key != "" {
+				r.Header.Set(ducktape.APIKeyHeader, key)
+			}
+			w := httptest.NewRecorder()
+			authenticated.ServeHTTP(w, r)
+			var response ducktape.HealthResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("failed to unmarshal %q: %v", w.Body.String(), err)
+			}
+			check := checks(response)["disk"]
+			if w.Code != http.StatusServiceUnavailable || check.Status != ducktape.HealthStatusFail {
+				t.Errorf("key %q: expected the disk check to fail, got %d %+v", key, w.Code, check)
+			}
+			if hasDetails := check.Error != nil || check.Detail != ""; hasDetails != expectDetails {
+				t.Errorf("key %q: expected details=%t, got %+v", key, expectDetails, check)
+			}
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		response := runHealthChecks(ctx, []healthCheck{{name: "stuck", run: func(context.Context) (string, error) {
+			time.Sleep(time.Second)
+			return "", nil
+		}}})
+		if response.Status != ducktape.HealthStatusFail || response.Checks[0].Error == nil {
+			t.Errorf("expected a check that does not complete in time to fail, got %+v", response)
+		}
+	})
+}
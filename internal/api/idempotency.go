@@ -0,0 +1,105 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/duckdb/duckdb-go/v2"
+)
+
+// idempotencyTable stores the response of every write that was sent with an idempotency key.
+// It lives in the database being written to so the key is committed atomically with the write itself.
+const idempotencyTable = "ducktape_idempotency_keys"
+
+var (
+	// ErrIdempotencyKeyReused is returned when a key is sent again with a different request, the stored response
+	// belongs to the first one.
+	ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different request")
+	// ErrIdempotencyKeyConflict is returned to the request that lost the race against a concurrent request with the
+	// same key, nothing it wrote was committed.
+	ErrIdempotencyKeyConflict = errors.New("a concurrent request with the same idempotency key was committed first")
+)
+
+type idempotencyStore interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// qualifiedIdempotencyTable returns the bookkeeping table name, scoped to database when one is provided.
+func qualifiedIdempotencyTable(database string) string {
+	if database == "" {
+		return idempotencyTable
+	}
+	return fmt.Sprintf(`"%s".main.%s`, strings.ReplaceAll(database, `"`, `""`), idempotencyTable)
+}
+
+// newRequestHash starts the fingerprint of a request sent with an idempotency key, the caller writes the request to it.
+func newRequestHash(route string) hash.Hash {
+	h := sha256.New()
+	h.Write([]byte(route))
+	h.Write([]byte{0})
+	return h
+}
+
+func requestHashString(h hash.Hash) string {
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// lookupIdempotencyKey creates the bookkeeping table if needed and returns the stored response for the key, if any,
+// with the hash of the request it was stored for.
+func lookupIdempotencyKey(ctx context.Context, store idempotencyStore, table string, route string, key string) ([]byte, string, bool, error) {
+	createQuery := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		route VARCHAR NOT NULL,
+		idempotency_key VARCHAR NOT NULL,
+		response VARCHAR NOT NULL,
+		request_hash VARCHAR NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+		PRIMARY KEY (route, idempotency_key)
+	)`, table)
+	if _, err := store.ExecContext(ctx, createQuery); err != nil {
+		return nil, "", false, fmt.Errorf("failed to create the idempotency table: %w", idempotencyConflict(err))
+	}
+
+	var response, requestHash string
+	err := store.QueryRowContext(ctx, fmt.Sprintf("SELECT response, request_hash FROM %s WHERE route = ? AND idempotency_key = ?", table), route, key).Scan(&response, &requestHash)
+	if err == sql.ErrNoRows {
+		return nil, "", false, nil
+	}
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to look up the idempotency key: %w", err)
+	}
+	return []byte(response), requestHash, true, nil
+}
+
+// checkRequestHash rejects the replay of a key that was stored for a different request.
+func checkRequestHash(stored string, requestHash string) error {
+	if stored != requestHash {
+		return ErrIdempotencyKeyReused
+	}
+	return nil
+}
+
+func storeIdempotencyKey(ctx context.Context, store idempotencyStore, table string, route string, key string, requestHash string, response []byte) error {
+	query := fmt.Sprintf("INSERT INTO %s (route, idempotency_key, request_hash, response) VALUES (?, ?, ?, ?)", table)
+	if _, err := store.ExecContext(ctx, query, route, key, requestHash, string(response)); err != nil {
+		return fmt.Errorf("failed to store the idempotency key: %w", idempotencyConflict(err))
+	}
+	return nil
+}
+
+// idempotencyConflict wraps the errors of a write that lost the race against a concurrent request with the same key:
+// the insert of the key violates its primary key, or the commit does once the other request has committed. The first
+// requests with keys also race to create the table.
+func idempotencyConflict(err error) error {
+	var duckdbErr *duckdb.Error
+	if errors.As(err, &duckdbErr) && (duckdbErr.Type == duckdb.ErrorTypeConstraint || duckdbErr.Type == duckdb.ErrorTypeTransaction) {
+		return fmt.Errorf("%w: %w", ErrIdempotencyKeyConflict, err)
+	}
+	return err
+}
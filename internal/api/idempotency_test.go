@@ -0,0 +1,226 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+	_ "github.com/duckdb/duckdb-go/v2"
+)
+
+func TestIdempotency(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("execute replays the stored response", func(t *testing.T) {
+		dsn := "test_idempotency_execute.db"
+		t.Cleanup(func() { os.Remove(dsn) })
+
+		_, err := Execute(ctx, dsn, ducktape.ExecuteRequest{
+			Statements: []ducktape.ExecuteStatement{
+				{Query: `CREATE TABLE test_idempotency_execute (id INTEGER)`},
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to create table: %v", err)
+		}
+
+		request := ducktape.ExecuteRequest{
+			Statements: []ducktape.ExecuteStatement{
+				{Query: `INSERT INTO test_idempotency_execute VALUES (1), (2)`},
+			},
+		}
+
+		for i, expectedReplayed := range []bool{false, true} {
+			result, replayed, err := ExecuteIdempotent(ctx, dsn, "key-1", request)
+			if err != nil {
+				t.Fatalf("attempt %d: failed to execute: %v", i, err)
+			}
+			if replayed != expectedReplayed {
+				t.Errorf("attempt %d: expected replayed=%t, got %t", i, expectedReplayed, replayed)
+			}
+			if rowsAffected, _ := result.RowsAffected(); rowsAffected != 2 {
+				t.Errorf("attempt %d: expected 2 rows affected, got %d", i, rowsAffected)
+			}
+		}
+
+		rows, err := Query(ctx, dsn, ducktape.QueryRequest{Query: "SELECT * FROM test_idempotency_execute"})
+		if err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+		if len(rows) != 2 {
+			t.Errorf("expected 2 rows (insert applied once), got %d", len(rows))
+		}
+	})
+
+	t.Run("failed execute does not store the key", func(t *testing.T) {
+		dsn := "test_idempotency_failed.db"
+		t.Cleanup(func() { os.Remove(dsn) })
+
+		_, _, err := ExecuteIdempotent(ctx, dsn, "key-1", ducktape.ExecuteRequest{
+			Statements: []ducktape.ExecuteStatement{{Query: "INVALID SQL"}},
+		})
+		if err == nil {
+			t.Fatal("expected error for invalid SQL, got none")
+		}
+
+		_, replayed, err := ExecuteIdempotent(ctx, dsn, "key-1", ducktape.ExecuteRequest{
+			Statements: []ducktape.ExecuteStatement{{Query: "CREATE TABLE test_idempotency_failed (id INTEGER)"}},
+		})
+		if err != nil {
+			t.Fatalf("failed to execute: %v", err)
+		}
+		if replayed {
+			t.Error("expected the retry to run since the first attempt failed")
+		}
+	})
+
+	t.Run("append replays the stored response", func(t *testing.T) {
+		dsn := "test_idempotency_append.db"
+		t.Cleanup(func() { os.Remove(dsn) })
+
+		_, err := Execute(ctx, dsn, ducktape.ExecuteRequest{
+			Statements: []ducktape.ExecuteStatement{
+				{Query: `CREATE TABLE test_idempotency_append (id INTEGER, name VARCHAR)`},
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to create table: %v", err)
+		}
+
+		ndjson := `{"rv":[1,"Alice"]}
+{"rv":[2,"Bob"]}`
+
+		for i, expectedReplayed := range []bool{false, true} {
+			rowsAppended, _, replayed, err := AppendIdempotent(ctx, dsn, "key-1", "test_idempotency_append", "main", "test_idempotency_append", strings.NewReader(ndjson))
+			if err != nil {
+				t.Fatalf("attempt %d: failed to append: %v", i, err)
+			}
+			if replayed != expectedReplayed {
+				t.Errorf("attempt %d: expected replayed=%t, got %t", i, expectedReplayed, replayed)
+			}
+			if rowsAppended != 2 {
+				t.Errorf("attempt %d: expected 2 rows appended, got %d", i, rowsAppended)
+			}
+		}
+
+		rows, err := Query(ctx, dsn, ducktape.QueryRequest{Query: "SELECT * FROM test_idempotency_append"})
+		if err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+		if len(rows) != 2 {
+			t.Errorf("expected 2 rows (append applied once), got %d", len(rows))
+		}
+	})
+
+	t.Run("failed append rolls back rows", func(t *testing.T) {
+		dsn := "test_idempotency_append_failed.db"
+		t.Cleanup(func() { os.Remove(dsn) })
+
+		_, err := Execute(ctx, dsn, ducktape.ExecuteRequest{
+			Statements: []ducktape.ExecuteStatement{
+				{Query: `CREATE TABLE test_idempotency_append_failed (id INTEGER)`},
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to create table: %v", err)
+		}
+
+		ndjson := `{"rv":[1]}
+not json`
+		_, _, _, err = AppendIdempotent(ctx, dsn, "key-1", "test_idempotency_append_failed", "main", "test_idempotency_append_failed", strings.NewReader(ndjson))
+		if err == nil {
+			t.Fatal("expected error for invalid row, got none")
+		}
+
+		rows, err := Query(ctx, dsn, ducktape.QueryRequest{Query: "SELECT * FROM test_idempotency_append_failed"})
+		if err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+		if len(rows) != 0 {
+			t.Errorf("expected 0 rows after failed append, got %d", len(rows))
+		}
+	})
+
+	t.Run("reused key with a different request", func(t *testing.T) {
+		dsn := "test_idempotency_reused.db"
+		t.Cleanup(func() { os.Remove(dsn) })
+
+		_, err := Execute(ctx, dsn, ducktape.ExecuteRequest{
+			Statements: []ducktape.ExecuteStatement{{Query: `CREATE TABLE test_idempotency_reused (id INTEGER)`}},
+		})
+		if err != nil {
+			t.Fatalf("failed to create table: %v", err)
+		}
+
+		insert := func(id int) ducktape.ExecuteRequest {
+			return ducktape.ExecuteRequest{Statements: []ducktape.ExecuteStatement{
+				{Query: "INSERT INTO test_idempotency_reused VALUES (?)", Args: []any{id}},
+			}}
+		}
+		if _, _, err := ExecuteIdempotent(ctx, dsn, "key-1", insert(1)); err != nil {
+			t.Fatalf("failed to execute: %v", err)
+		}
+		if _, _, err := ExecuteIdempotent(ctx, dsn, "key-1", insert(2)); !errors.Is(err, ErrIdempotencyKeyReused) {
+			t.Errorf("expected the reused key to be rejected, got %v", err)
+		}
+
+		_, _, _, err = AppendIdempotent(ctx, dsn, "key-2", "test_idempotency_reused", "main", "test_idempotency_reused", strings.NewReader(`{"rv":[3]}`))
+		if err != nil {
+			t.Fatalf("failed to append: %v", err)
+		}
+		_, _, _, err = AppendIdempotent(ctx, dsn, "key-2", "test_idempotency_reused", "main", "test_idempotency_reused", strings.NewReader(`{"rv":[4]}`))
+		if !errors.Is(err, ErrIdempotencyKeyReused) {
+			t.Errorf("expected the reused key to be rejected, got %v", err)
+		}
+
+		rows, err := Query(ctx, dsn, ducktape.QueryRequest{Query: "SELECT * FROM test_idempotency_reused"})
+		if err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+		if len(rows) != 2 {
+			t.Errorf("expected only the first writes of each key, got %v", rows)
+		}
+	})
+
+	t.Run("concurrent requests with the same key", func(t *testing.T) {
+		dsn := "test_idempotency_concurrent.db"
+		t.Cleanup(func() { os.Remove(dsn) })
+
+		db, err := openDB(ctx, dsn, "test")
+		if err != nil {
+			t.Fatalf("failed to open the database: %v", err)
+		}
+		defer db.Close()
+		if _, _, _, err := lookupIdempotencyKey(ctx, db, idempotencyTable, ducktape.ExecuteRoute, "key-1"); err != nil {
+			t.Fatalf("failed to create the idempotency table: %v", err)
+		}
+
+		// Both requests miss the key before either one has committed
+		var txs []*sql.Tx
+		for range 2 {
+			tx, err := db.BeginTx(ctx, nil)
+			if err != nil {
+				t.Fatalf("failed to begin a transaction: %v", err)
+			}
+			defer tx.Rollback()
+			if _, _, found, err := lookupIdempotencyKey(ctx, tx, idempotencyTable, ducktape.ExecuteRoute, "key-1"); err != nil || found {
+				t.Fatalf("expected the key to be missing, got found=%t err=%v", found, err)
+			}
+			if err := storeIdempotencyKey(ctx, tx, idempotencyTable, ducktape.ExecuteRoute, "key-1", "hash", []byte("{}")); err != nil {
+				t.Fatalf("failed to store the key: %v", err)
+			}
+			txs = append(txs, tx)
+		}
+
+		if err := txs[0].Commit(); err != nil {
+			t.Fatalf("failed to commit: %v", err)
+		}
+		if err := idempotencyConflict(txs[1].Commit()); !errors.Is(err, ErrIdempotencyKeyConflict) {
+			t.Errorf("expected the second commit to conflict, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,532 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+	"github.com/artie-labs/ducktape/internal/utils"
+)
+
+const defaultJobResultTTL = time.Hour
+
+var (
+	ErrJobNotFound    = errors.New("job not found")
+	ErrJobNotFinished = errors.New("job has not finished")
+	ErrJobFailed      = errors.New("job did not succeed")
+)
+
+var jobs = newJobStore()
+
+// job is a query that runs in the background and stores its results as a Parquet file.
+// Jobs are registered as operations under the same ID, so they show up in [ListOperations] while running.
+type job struct {
+	id          string
+	dsn         string
+	sql         string
+	path        string
+	submittedAt time.Time
+	// principal is the caller that submitted the job, only it can read or cancel the job.
+	principal string
+
+	// Guarded by jobStore.mu
+	state       ducktape.JobState
+	finishedAt  time.Time
+	expiresAt   time.Time
+	rows        int64
+	resultBytes int64
+	err         error
+}
+
+func (j *job) toResponse() ducktape.Job {
+	response := ducktape.Job{
+		ID:          j.id,
+		DSN:         j.dsn,
+		SQL:         j.sql,
+		State:       j.state,
+		SubmittedAt: j.submittedAt,
+		Rows:        j.rows,
+		ResultBytes: j.resultBytes,
+	}
+	if j.state.Finished() {
+		finishedAt, expiresAt := j.finishedAt, j.expiresAt
+		response.FinishedAt = &finishedAt
+		response.ExpiresAt = &expiresAt
+	}
+	if j.err != nil {
+		errMsg := j.err.Error()
+		response.Error = &errMsg
+	}
+	if j.state == ducktape.JobStateRunning {
+		progress := ducktape.JobProgress{ElapsedSeconds: time.Since(j.submittedAt).Seconds()}
+		// The results file does not exist until DuckDB has started writing it
+		if info, err := os.Stat(j.path); err == nil {
+			progress.BytesWritten = info.Size()
+		}
+		response.Progress = &progress
+	}
+	return response
+}
+
+// jobStore holds submitted jobs and discards their results once they have been finished for longer than
+// [Options.JobResultTTL].
+type jobStore struct {
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+func newJobStore() *jobStore {
+	return &jobStore{jobs: make(map[string]*job)}
+}
+
+func jobDir() string {
+	if options.JobDir != "" {
+		return options.JobDir
+	}
+	return filepath.Join(os.TempDir(), "ducktape-jobs")
+}
+
+func jobResultTTL() time.Duration {
+	if options.JobResultTTL > 0 {
+		return options.JobResultTTL
+	}
+	return defaultJobResultTTL
+}
+
+// submit starts the query in the background, it is not bound to the submitting request.
+func (s *jobStore) submit(dsn string, request ducktape.QueryRequest, timeout time.Duration, principal string, clientAddress string) (ducktape.Job, error) {
+	if request.Query == "" {
+		return ducktape.Job{}, fmt.Errorf("query is required")
+	}
+	if shuttingDown.Load() {
+		return ducktape.Job{}, fmt.Errorf("%w: jobs cannot be submitted", ErrShuttingDown)
+	}
+	if err := os.MkdirAll(jobDir(), 0o700); err != nil {
+		return ducktape.Job{}, fmt.Errorf("failed to create the job directory: %w", err)
+	}
+
+	ctx, cancel := withTimeout(context.Background(), timeout)
+	ctx, op := operations.start(ctx, "job", dsn, request.Query, clientAddress)
+	j := &job{
+		id:          op.id,
+		dsn:         dsn,
+		sql:         request.Query,
+		path:        filepath.Join(jobDir(), op.id+".parquet"),
+		submittedAt: op.startedAt,
+		principal:   principal,
+		state:       ducktape.JobStateRunning,
+	}
+
+	s.mu.Lock()
+	s.jobs[j.id] = j
+	response := j.toResponse()
+	s.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		defer operations.finish(op)
+		s.run(ctx, j, request)
+	}()
+	return response, nil
+}
+
+func (s *jobStore) run(ctx context.Context, j *job, request ducktape.QueryRequest) {
+	start := time.Now()
+	rows, err := copyToParquet(ctx, j.dsn, request, j.path)
+	err = contextError(ctx, err)
+
+	var resultBytes int64
+	if err == nil {
+		info, statErr := os.Stat(j.path)
+		if statErr != nil {
+			err = fmt.Errorf("failed to stat the job results: %w", statErr)
+		} else {
+			resultBytes = info.Size()
+		}
+	}
+	if err != nil {
+		os.Remove(j.path)
+	}
+
+	ttl := jobResultTTL()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j.finishedAt = time.Now()
+	j.expiresAt = j.finishedAt.Add(ttl)
+	switch {
+	case err == nil:
+		j.state = ducktape.JobStateSucceeded
+		j.rows = rows
+		j.resultBytes = resultBytes
+	case errors.Is(err, ErrOperationCancelled):
+		j.state = ducktape.JobStateCancelled
+		j.err = err
+	default:
+		j.state = ducktape.JobStateFailed
+		j.err = err
+	}
+	time.AfterFunc(ttl, func() { s.expire(j) })
+
+	slog.Info("job finished", slog.String("jobId", j.id), slog.String("state", string(j.state)), slog.Int64("rows", j.rows),
+		slog.Duration("elapsed", time.Since(start)), slog.Any("error", err))
+}
+
+// copyToParquet runs the query and writes its results to path, returning the number of rows written.
+func copyToParquet(ctx context.Context, dsn string, request ducktape.QueryRequest, path string) (int64, error) {
+	query, args, err := bindArgs(request.Query, request.Args, request.NamedArgs)
+	if err != nil {
+		return 0, err
+	}
+
+	db, err := openDB(ctx, dsn, "jobs")
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+	slog.Debug("running duckdb job", slog.String("query", query), slog.Any("args", request.Args), slog.Any("namedArgs", request.NamedArgs), slog.String("path", path))
+
+	copyCtx, span := startSpan(ctx, "duckdb.copy", queryText(request.Query))
+	result, err := db.ExecContext(copyCtx, fmt.Sprintf("COPY (%s) TO %s (FORMAT parquet)", query, quoteLiteral(path)), args...)
+	endSpan(span, err)
+	if err != nil {
+		return 0, fmt.Errorf("failed to run the job query: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get the number of rows written: %w", err)
+	}
+	return rows, nil
+}
+
+// quoteLiteral quotes s as a SQL string literal.
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// lookup must be called with s.mu held.
+func (s *jobStore) lookup(ctx context.Context, id string) (*job, error) {
+	j, ok := s.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrJobNotFound, id)
+	}
+	if err := checkOwner(ctx, j.principal); err != nil {
+		return nil, fmt.Errorf("job %q: %w", id, err)
+	}
+	return j, nil
+}
+
+func (s *jobStore) get(ctx context.Context, id string) (ducktape.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, err := s.lookup(ctx, id)
+	if err != nil {
+		return ducktape.Job{}, err
+	}
+	return j.toResponse(), nil
+}
+
+// resultPath returns the Parquet file of a job that has succeeded.
+func (s *jobStore) resultPath(ctx context.Context, id string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, err := s.lookup(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	switch j.state {
+	case ducktape.JobStateSucceeded:
+		return j.path, nil
+	case ducktape.JobStateRunning:
+		return "", fmt.Errorf("%w: %q is %s", ErrJobNotFinished, id, j.state)
+	default:
+		return "", fmt.Errorf("%w: %q is %s: %w", ErrJobFailed, id, j.state, j.err)
+	}
+}
+
+// cancel interrupts a running job, cancelling a finished job is a no-op.
+func (s *jobStore) cancel(ctx context.Context, id string) (ducktape.Job, error) {
+	s.mu.Lock()
+	j, err := s.lookup(ctx, id)
+	if err != nil {
+		s.mu.Unlock()
+		return ducktape.Job{}, err
+	}
+	response := j.toResponse()
+	s.mu.Unlock()
+
+	if response.State.Finished() {
+		return response, nil
+	}
+	// The job may finish in the meantime, in which case its operation is gone and there is nothing left to cancel.
+	if err := operations.cancel(id); err != nil && !errors.Is(err, ErrOperationNotFound) {
+		return ducktape.Job{}, err
+	}
+	return response, nil
+}
+
+func (s *jobStore) expire(j *job) {
+	s.mu.Lock()
+	if s.jobs[j.id] != j {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.jobs, j.id)
+	s.mu.Unlock()
+
+	slog.Debug("discarding expired job results", slog.String("jobId", j.id))
+	if err := os.Remove(j.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Error("failed to remove expired job results", slog.String("jobId", j.id), slog.Any("error", err))
+	}
+}
+
+// SubmitJob runs the query in the background and returns immediately, poll [GetJob] until the job has finished and
+// read its results from [JobResultPath]. A timeout of zero means no limit.
+func SubmitJob(dsn string, request ducktape.QueryRequest, timeout time.Duration) (ducktape.Job, error) {
+	if err := checkReadOnly(context.Background(), dsn, request.Query); err != nil {
+		return ducktape.Job{}, err
+	}
+	return jobs.submit(dsn, request, timeout, "", "")
+}
+
+func GetJob(id string) (ducktape.Job, error) {
+	return jobs.get(context.Background(), id)
+}
+
+// CancelJob interrupts a running job, its state becomes [ducktape.JobStateCancelled].
+func CancelJob(id string) (ducktape.Job, error) {
+	return jobs.cancel(context.Background(), id)
+}
+
+// JobResultPath returns the Parquet file holding the results of a job that has succeeded.
+// The file is removed once the results expire.
+func JobResultPath(id string) (string, error) {
+	return jobs.resultPath(context.Background(), id)
+}
+
+func handleSubmitJob(w http.ResponseWriter, r *http.Request) {
+	dsn := r.Header.Get(ducktape.DuckDBConnectionStringHeader)
+	if dsn == "" {
+		err := fmt.Errorf("%q header is required", ducktape.DuckDBConnectionStringHeader)
+		errMsg := err.Error()
+		handleBadRequestJSON(w, ducktape.JobResponse{Error: &errMsg}, err)
+		return
+	}
+
+	request, err := getRequestBody[ducktape.QueryRequest](r)
+	if err != nil {
+		errMsg := err.Error()
+		handleRequestBodyErrorJSON(w, ducktape.JobResponse{Error: &errMsg}, err)
+		return
+	}
+	if request.DryRun || request.PageSize != 0 || request.Cursor != "" {
+		err := fmt.Errorf("dry run and pagination are not supported for jobs")
+		errMsg := err.Error()
+		handleBadRequestJSON(w, ducktape.JobResponse{Error: &errMsg}, err)
+		return
+	}
+	// Jobs are meant for queries that outlast a request, so they are not bound by [Options.MaxQueryDuration]
+	timeout, err := requestTimeout(r, options.MaxJobDuration)
+	if err != nil {
+		errMsg := err.Error()
+		handleBadRequestJSON(w, ducktape.JobResponse{Error: &errMsg}, err)
+		return
+	}
+
+	ctx, err := withReadOnlyHeader(r.Context(), r)
+	if err != nil {
+		errMsg := err.Error()
+		handleBadRequestJSON(w, ducktape.JobResponse{Error: &errMsg}, err)
+		return
+	}
+	// The job outlives the request, so its read-only mode is checked up front
+	if err := checkReadOnly(ctx, dsn, request.Query); err != nil {
+		errMsg := err.Error()
+		handleErrorJSON(w, ducktape.JobResponse{Error: &errMsg}, err)
+		return
+	}
+
+	job, err := jobs.submit(dsn, request, timeout, principalName(r.Context()), r.RemoteAddr)
+	if err != nil {
+		errMsg := err.Error()
+		handleErrorJSON(w, ducktape.JobResponse{Error: &errMsg}, err)
+		return
+	}
+	writeJobJSON(w, http.StatusAccepted, job)
+	LoggerFromContext(r.Context()).Info("submitted job", slog.String("jobId", job.ID), slog.String("clientAddress", r.RemoteAddr))
+}
+
+func handleGetJob(w http.ResponseWriter, r *http.Request) {
+	job, err := jobs.get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		errMsg := err.Error()
+		handleErrorJSON(w, ducktape.JobResponse{Error: &errMsg}, err)
+		return
+	}
+	writeJobJSON(w, http.StatusOK, job)
+}
+
+func handleCancelJob(w http.ResponseWriter, r *http.Request) {
+	job, err := jobs.cancel(r.Context(), r.PathValue("id"))
+	if err != nil {
+		errMsg := err.Error()
+		handleErrorJSON(w, ducktape.JobResponse{Error: &errMsg}, err)
+		return
+	}
+	writeJobJSON(w, http.StatusOK, job)
+	LoggerFromContext(r.Context()).Info("cancelled job", slog.String("jobId", job.ID), slog.String("clientAddress", r.RemoteAddr))
+}
+
+func writeJobJSON(w http.ResponseWriter, statusCode int, job ducktape.Job) {
+	body, err := json.Marshal(ducktape.JobResponse{Job: &job})
+	if err != nil {
+		err := fmt.Errorf("failed to marshal the response: %v", err)
+		errMsg := err.Error()
+		handleInternalServerErrorJSON(w, ducktape.JobResponse{Error: &errMsg}, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
+
+func handleJobResults(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	format := ducktape.JobResultFormat(r.URL.Query().Get("format"))
+	if format == "" {
+		format = ducktape.JobResultFormatJSON
+	}
+
+	path, err := jobs.resultPath(r.Context(), id)
+	if err != nil {
+		errMsg := err.Error()
+		handleErrorJSON(w, ducktape.QueryResponse{Error: &errMsg}, err)
+		return
+	}
+
+	switch format {
+	case ducktape.JobResultFormatParquet:
+		err = writeParquetFile(w, id, path)
+	case ducktape.JobResultFormatJSON, ducktape.JobResultFormatNDJSON:
+		err = writeParquetRows(r.Context(), w, path, format)
+	default:
+		err = fmt.Errorf("unsupported result format %q", format)
+		errMsg := err.Error()
+		handleBadRequestJSON(w, ducktape.QueryResponse{Error: &errMsg}, err)
+		return
+	}
+	if err != nil {
+		errMsg := err.Error()
+		handleErrorJSON(w, ducktape.QueryResponse{Error: &errMsg}, err)
+	}
+}
+
+func writeParquetFile(w http.ResponseWriter, id string, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open the job results: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat the job results: %w", err)
+	}
+	w.Header().Set("Content-Type", "application/vnd.apache.parquet")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", id+".parquet"))
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, file); err != nil {
+		responseLogger(w).Error("failed to stream job results", slog.String("jobId", id), slog.Any("error", err))
+	}
+	return nil
+}
+
+// writeParquetRows streams the rows of a Parquet file as JSON. Once the first byte is written the status can no longer
+// change, so a JSON response reports a late failure in its error field and an NDJSON response is aborted.
+func writeParquetRows(ctx context.Context, w http.ResponseWriter, path string, format ducktape.JobResultFormat) error {
+	db, err := openScratchDB("job results")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, "SELECT * FROM read_parquet(?)", path)
+	if err != nil {
+		return fmt.Errorf("failed to read the job results: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return fmt.Errorf("failed to read the job results: %w", err)
+	}
+
+	if format == ducktape.JobResultFormatNDJSON {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		err := forEachObject(rows, columns, func(encoded []byte) {
+			w.Write(encoded)
+			w.Write([]byte("\n"))
+		})
+		if err != nil {
+			LoggerFromContext(ctx).Error("failed to stream job results", slog.Any("error", err))
+			panic(http.ErrAbortHandler)
+		}
+		return nil
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"rows":[`))
+	separator := ""
+	err = forEachObject(rows, columns, func(encoded []byte) {
+		w.Write([]byte(separator))
+		w.Write(encoded)
+		separator = ","
+	})
+
+	var errMsg *string
+	if err != nil {
+		LoggerFromContext(ctx).Error("failed to stream job results", slog.Any("error", err))
+		msg := err.Error()
+		errMsg = &msg
+	}
+	trailer, _ := json.Marshal(errMsg)
+	fmt.Fprintf(w, `],"error":%s}`, trailer)
+	return nil
+}
+
+// forEachObject calls fn with the JSON encoding of each row.
+func forEachObject(rows *sql.Rows, columns []string, fn func(encoded []byte)) error {
+	for rows.Next() {
+		object, err := utils.ScanObject(rows, columns)
+		if err != nil {
+			return fmt.Errorf("failed to convert rows to objects: %w", err)
+		}
+		encoded, err := json.Marshal(object)
+		if err != nil {
+			return fmt.Errorf("failed to marshal the row: %w", err)
+		}
+		fn(encoded)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate over rows: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+	_ "github.com/duckdb/duckdb-go/v2"
+)
+
+func waitForJob(t *testing.T, id string) ducktape.Job {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := GetJob(id)
+		if err != nil {
+			t.Fatalf("failed to get job: %v", err)
+		}
+		if job.State.Finished() {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %q did not finish", id)
+	return ducktape.Job{}
+}
+
+func TestJobs(t *testing.T) {
+	ctx := context.Background()
+	dsn := "test_jobs.db"
+	Configure(Options{JobDir: t.TempDir()})
+	t.Cleanup(func() {
+		Configure(Options{})
+		os.Remove(dsn)
+	})
+
+	_, err := Execute(ctx, dsn, ducktape.ExecuteRequest{
+		Statements: []ducktape.ExecuteStatement{
+			{Query: `CREATE TABLE test_jobs AS SELECT range AS id, 'row ' || range AS name FROM range(5)`},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	t.Run("results are stored as parquet", func(t *testing.T) {
+		job, err := SubmitJob(dsn, ducktape.QueryRequest{Query: "SELECT * FROM test_jobs WHERE id >= ? ORDER BY id;", Args: []any{2}}, 0)
+		if err != nil {
+			t.Fatalf("failed to submit job: %v", err)
+		}
+		if job.State != ducktape.JobStateRunning {
+			t.Errorf("expected a running job, got %q", job.State)
+		}
+
+		job = waitForJob(t, job.ID)
+		if job.State != ducktape.JobStateSucceeded {
+			t.Fatalf("expected the job to succeed, got %q (%v)", job.State, job.Error)
+		}
+		if job.Rows != 3 {
+			t.Errorf("expected 3 rows, got %d", job.Rows)
+		}
+		if job.ResultBytes == 0 || job.FinishedAt == nil || job.ExpiresAt == nil {
+			t.Errorf("expected result size and timestamps to be set, got %+v", job)
+		}
+
+		path, err := JobResultPath(job.ID)
+		if err != nil {
+			t.Fatalf("failed to get the result path: %v", err)
+		}
+		rows, err := Query(ctx, "", ducktape.QueryRequest{Query: "SELECT * FROM read_parquet(?) ORDER BY id", Args: []any{path}})
+		if err != nil {
+			t.Fatalf("failed to read the results: %v", err)
+		}
+		if len(rows) != 3 || rows[0]["name"] != "row 2" {
+			t.Errorf("unexpected results: %v", rows)
+		}
+	})
+
+	t.Run("results are streamed as json and ndjson", func(t *testing.T) {
+		job, err := SubmitJob(dsn, ducktape.QueryRequest{Query: "SELECT id FROM test_jobs ORDER BY id LIMIT 2"}, 0)
+		if err != nil {
+			t.Fatalf("failed to submit job: %v", err)
+		}
+		waitForJob(t, job.ID)
+
+		for format, expected := range map[ducktape.JobResultFormat]string{
+			ducktape.JobResultFormatJSON:   `{"rows":[{"id":0},{"id":1}],"error":null}`,
+			ducktape.JobResultFormatNDJSON: "{\"id\":0}\n{\"id\":1}\n",
+		} {
+			r := httptest.NewRequest("GET", "/api/jobs/"+job.ID+"/results?format="+string(format), nil)
+			r.SetPathValue("id", job.ID)
+			w := httptest.NewRecorder()
+			handleJobResults(w, r)
+
+			if w.Code != 200 {
+				t.Fatalf("%s: expected status 200, got %d: %s", format, w.Code, w.Body.String())
+			}
+			if w.Body.String() != expected {
+				t.Errorf("%s: expected %q, got %q", format, expected, w.Body.String())
+			}
+		}
+	})
+
+	t.Run("failed job", func(t *testing.T) {
+		job, err := SubmitJob(dsn, ducktape.QueryRequest{Query: "SELECT * FROM missing_table"}, 0)
+		if err != nil {
+			t.Fatalf("failed to submit job: %v", err)
+		}
+
+		job = waitForJob(t, job.ID)
+		if job.State != ducktape.JobStateFailed || job.Error == nil {
+			t.Errorf("expected the job to fail with an error, got %+v", job)
+		}
+		if _, err := JobResultPath(job.ID); !errors.Is(err, ErrJobFailed) {
+			t.Errorf("expected ErrJobFailed, got %v", err)
+		}
+	})
+
+	t.Run("cancel a running job", func(t *testing.T) {
+		job, err := SubmitJob(dsn, ducktape.QueryRequest{Query: "SELECT count(*) FROM range(1000000000000) a"}, 0)
+		if err != nil {
+			t.Fatalf("failed to submit job: %v", err)
+		}
+		if _, err := JobResultPath(job.ID); !errors.Is(err, ErrJobNotFinished) {
+			t.Errorf("expected ErrJobNotFinished, got %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+		if job, err := GetJob(job.ID); err != nil || job.Progress == nil || job.Progress.ElapsedSeconds <= 0 {
+			t.Errorf("expected the progress of the running job, got %+v (%v)", job.Progress, err)
+		}
+
+		if _, err := CancelJob(job.ID); err != nil {
+			t.Fatalf("failed to cancel job: %v", err)
+		}
+		job = waitForJob(t, job.ID)
+		if job.State != ducktape.JobStateCancelled || job.Progress != nil {
+			t.Errorf("expected the job to be cancelled without progress, got %+v", job)
+		}
+	})
+
+	t.Run("job timeout", func(t *testing.T) {
+		job, err := SubmitJob(dsn, ducktape.QueryRequest{Query: "SELECT count(*) FROM range(1000000000000) a"}, 50*time.Millisecond)
+		if err != nil {
+			t.Fatalf("failed to submit job: %v", err)
+		}
+
+		job = waitForJob(t, job.ID)
+		if job.State != ducktape.JobStateFailed || job.Error == nil || !strings.Contains(*job.Error, ErrQueryTimeout.Error()) {
+			t.Errorf("expected the job to time out, got %+v", job)
+		}
+	})
+
+	t.Run("job of another principal", func(t *testing.T) {
+		job, err := jobs.submit(dsn, ducktape.QueryRequest{Query: "SELECT 1"}, 0, "alice", "")
+		if err != nil {
+			t.Fatalf("failed to submit job: %v", err)
+		}
+		waitForJob(t, job.ID)
+
+		bob := WithPrincipal(ctx, Principal{Name: "bob"})
+		if _, err := jobs.get(bob, job.ID); !errors.Is(err, ErrForbidden) {
+			t.Errorf("expected ErrForbidden for the job, got %v", err)
+		}
+		if _, err := jobs.resultPath(bob, job.ID); !errors.Is(err, ErrForbidden) {
+			t.Errorf("expected ErrForbidden for the results, got %v", err)
+		}
+		if _, err := jobs.cancel(bob, job.ID); !errors.Is(err, ErrForbidden) {
+			t.Errorf("expected ErrForbidden for the cancel, got %v", err)
+		}
+		if _, err := jobs.get(WithPrincipal(ctx, Principal{Name: "alice"}), job.ID); err != nil {
+			t.Errorf("expected the owner to get the job, got %v", err)
+		}
+	})
+
+	t.Run("jobs are not bound by the query duration limit", func(t *testing.T) {
+		Configure(Options{JobDir: t.TempDir(), MaxQueryDuration: time.Nanosecond})
+		t.Cleanup(func() { Configure(Options{JobDir: t.TempDir()}) })
+
+		r := httptest.NewRequest("POST", "/api/jobs", strings.NewReader(`{"query": "SELECT 1"}`))
+		r.Header.Set(ducktape.DuckDBConnectionStringHeader, dsn)
+		w := httptest.NewRecorder()
+		handleSubmitJob(w, r)
+		if w.Code != 202 {
+			t.Fatalf("expected status 202, got %d: %s", w.Code, w.Body.String())
+		}
+		var response ducktape.JobResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to unmarshal the response: %v", err)
+		}
+
+		job := waitForJob(t, response.Job.ID)
+		if job.State != ducktape.JobStateSucceeded {
+			t.Errorf("expected the job to succeed, got %+v", job)
+		}
+	})
+
+	t.Run("results expire", func(t *testing.T) {
+		Configure(Options{JobDir: t.TempDir(), JobResultTTL: 50 * time.Millisecond})
+		job, err := SubmitJob(dsn, ducktape.QueryRequest{Query: "SELECT 1"}, 0)
+		if err != nil {
+			t.Fatalf("failed to submit job: %v", err)
+		}
+		waitForJob(t, job.ID)
+		path, err := JobResultPath(job.ID)
+		if err != nil {
+			t.Fatalf("failed to get the result path: %v", err)
+		}
+
+		time.Sleep(200 * time.Millisecond)
+		if _, err := GetJob(job.ID); !errors.Is(err, ErrJobNotFound) {
+			t.Errorf("expected ErrJobNotFound after expiry, got %v", err)
+		}
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("expected the results file to be removed, got %v", err)
+		}
+	})
+}
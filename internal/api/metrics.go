@@ -0,0 +1,158 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsScrapeTimeout bounds how long reading the memory usage of each open database may take during a scrape.
+const metricsScrapeTimeout = time.Second
+
+// metrics is the registry served by [RegisterMetricsRoutes].
+var metrics = prometheus.NewRegistry()
+
+var (
+	requestsTotal = promauto.With(metrics).NewCounterVec(prometheus.CounterOpts{
+		Name: "ducktape_http_requests_total",
+		Help: "API requests by route and status code.",
+	}, []string{"route", "code"})
+	requestDuration = promauto.With(metrics).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ducktape_http_request_duration_seconds",
+		Help:    "Duration of API requests by route and status code.",
+		Buckets: []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300},
+	}, []string{"route", "code"})
+
+	rowsReturnedTotal = promauto.With(metrics).NewCounter(prometheus.CounterOpts{
+		Name: "ducktape_rows_returned_total",
+		Help: "Rows read by queries and cursors.",
+	})
+	rowsAffectedTotal = promauto.With(metrics).NewCounter(prometheus.CounterOpts{
+		Name: "ducktape_rows_affected_total",
+		Help: "Rows affected by executed statements.",
+	})
+	rowsAppendedTotal = promauto.With(metrics).NewCounter(prometheus.CounterOpts{
+		Name: "ducktape_rows_appended_total",
+		Help: "Rows appended through the append route.",
+	})
+	appendBytesTotal = promauto.With(metrics).NewCounter(prometheus.CounterOpts{
+		Name: "ducktape_append_bytes_total",
+		Help: "NDJSON bytes ingested through the append route.",
+	})
+	appenderFlushDuration = promauto.With(metrics).NewHistogram(prometheus.HistogramOpts{
+		Name:    "ducktape_appender_flush_duration_seconds",
+		Help:    "Duration of appender flushes, its count is the number of flushes.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 4, 8),
+	})
+	resultCacheLookups = promauto.With(metrics).NewCounterVec(prometheus.CounterOpts{
+		Name: "ducktape_result_cache_lookups_total",
+		Help: "Result cache lookups by result, hit or miss.",
+	}, []string{"result"})
+)
+
+func init() {
+	metrics.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		serverCollector{},
+	)
+}
+
+var (
+	openDatabasesDesc = prometheus.NewDesc("ducktape_open_databases",
+		"DuckDB database instances held open, file databases count once however many handles share them.", nil, nil)
+	databaseMemoryDesc = prometheus.NewDesc("ducktape_duckdb_memory_bytes",
+		"Memory used by each open DuckDB database, from duckdb_memory(). In-memory databases are summed up.",
+		[]string{"database"}, nil)
+	operationsDesc = prometheus.NewDesc("ducktape_operations_in_flight",
+		"Query, execute, append and job operations in flight.", []string{"kind"}, nil)
+	statementCacheSizeDesc = prometheus.NewDesc("ducktape_statement_cache_size",
+		"Prepared statements in the statement cache.", nil, nil)
+	statementCacheHitsDesc = prometheus.NewDesc("ducktape_statement_cache_hits_total",
+		"Statement cache lookups that found a prepared statement.", nil, nil)
+	statementCacheMissesDesc = prometheus.NewDesc("ducktape_statement_cache_misses_total",
+		"Statement cache lookups that had to prepare the statement.", nil, nil)
+	statementCacheEvictionsDesc = prometheus.NewDesc("ducktape_statement_cache_evictions_total",
+		"Prepared statements evicted from the statement cache.", nil, nil)
+)
+
+// serverCollector reads the metrics that are state rather than events when scraped: open databases and their
+// memory, in-flight operations and the statement cache.
+type serverCollector struct{}
+
+func (serverCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		openDatabasesDesc, databaseMemoryDesc, operationsDesc,
+		statementCacheSizeDesc, statementCacheHitsDesc, statementCacheMissesDesc, statementCacheEvictionsDesc,
+	} {
+		ch <- desc
+	}
+}
+
+func (serverCollector) Collect(ch chan<- prometheus.Metric) {
+	// File databases opened several times share a single DuckDB instance, so each is only read once. The databases are
+	// read without holding the registry, which would block every database from being opened or closed meanwhile.
+	memory := make(map[string]float64)
+	var instances int
+	for connector, db := range openDatabases.list() {
+		database := ":memory:"
+		if !isInMemoryDSN(connector.path) {
+			database = databaseKey(connector.path)
+			if _, ok := memory[database]; ok {
+				continue
+			}
+		}
+		instances++
+		used, err := databaseMemory(db)
+		if err != nil {
+			slog.Debug("failed to read the memory usage of a database", slog.String("database", database), slog.Any("error", err))
+		}
+		memory[database] += used
+	}
+	ch <- prometheus.MustNewConstMetric(openDatabasesDesc, prometheus.GaugeValue, float64(instances))
+	for path, used := range memory {
+		ch <- prometheus.MustNewConstMetric(databaseMemoryDesc, prometheus.GaugeValue, used, path)
+	}
+
+	kinds := map[string]int{"query": 0, "execute": 0, "append": 0, "job": 0}
+	for _, op := range operations.list() {
+		kinds[op.Kind]++
+	}
+	for kind, count := range kinds {
+		ch <- prometheus.MustNewConstMetric(operationsDesc, prometheus.GaugeValue, float64(count), kind)
+	}
+
+	stats := GetStatementCacheStats()
+	ch <- prometheus.MustNewConstMetric(statementCacheSizeDesc, prometheus.GaugeValue, float64(stats.Size))
+	ch <- prometheus.MustNewConstMetric(statementCacheHitsDesc, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(statementCacheMissesDesc, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(statementCacheEvictionsDesc, prometheus.CounterValue, float64(stats.Evictions))
+}
+
+func databaseMemory(db *sql.DB) (float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), metricsScrapeTimeout)
+	defer cancel()
+	var used float64
+	err := db.QueryRowContext(ctx, "SELECT coalesce(sum(memory_usage_bytes), 0)::DOUBLE FROM duckdb_memory()").Scan(&used)
+	return used, err
+}
+
+// instrumentRoute counts the requests of a route and measures their duration, by status code.
+func instrumentRoute(pattern string, handler http.Handler) http.HandlerFunc {
+	labels := prometheus.Labels{"route": pattern}
+	return promhttp.InstrumentHandlerDuration(requestDuration.MustCurryWith(labels),
+		promhttp.InstrumentHandlerCounter(requestsTotal.MustCurryWith(labels), handler))
+}
+
+// RegisterMetricsRoutes serves the metrics of the server in the Prometheus text format. They are served behind the
+// authenticators, like the API, since the memory of each database is labelled with its path.
+func RegisterMetricsRoutes(mux *http.ServeMux, authenticators ...Authenticator) {
+	mux.Handle("GET /metrics", Authenticate(promhttp.HandlerFor(metrics, promhttp.HandlerOpts{}), authenticators...))
+}
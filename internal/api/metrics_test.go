@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+	_ "github.com/duckdb/duckdb-go/v2"
+)
+
+func TestMetrics(t *testing.T) {
+	dsn := "test_metrics.db"
+	t.Cleanup(func() {
+		os.Remove(dsn)
+		os.Remove(dsn + ".wal")
+	})
+
+	mux := http.NewServeMux()
+	RegisterApiRoutes(mux)
+	RegisterMetricsRoutes(mux)
+	serve := func(method string, route string, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, route, strings.NewReader(body))
+		r.Header.Set(ducktape.DuckDBConnectionStringHeader, dsn)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := serve("POST", ducktape.ExecuteRoute, `{"statements":[{"query":"CREATE TABLE test_metrics AS SELECT range AS id FROM range(3)"}]}`); w.Code != http.StatusOK {
+		t.Fatalf("failed to create table: %d %s", w.Code, w.Body.String())
+	}
+	if w := serve("POST", ducktape.QueryRoute, `{"query":"SELECT * FROM test_metrics"}`); w.Code != http.StatusOK {
+		t.Fatalf("failed to query: %d %s", w.Code, w.Body.String())
+	}
+	serve("POST", ducktape.QueryRoute, `{"query":`)
+
+	// A transaction keeps its database open
+	transactionID, err := BeginTransaction(dsn)
+	if err != nil {
+		t.Fatalf("failed to begin transaction: %v", err)
+	}
+	t.Cleanup(func() { RollbackTransaction(transactionID, dsn) })
+	// The same database spelled differently is a single instance
+	db, err := openDB(context.Background(), "./"+dsn, "test")
+	if err != nil {
+		t.Fatalf("failed to open the database: %v", err)
+	}
+	defer db.Close()
+
+	w := serve("GET", "/metrics", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("failed to scrape the metrics: %d", w.Code)
+	}
+	scraped := w.Body.String()
+	if strings.Contains(scraped, `database="./`+dsn+`"`) || strings.Contains(scraped, `database="`+dsn+`"`) {
+		t.Errorf("expected the memory of the database to be reported once, by its absolute path")
+	}
+	for _, expected := range []string{
+		`ducktape_http_requests_total{code="200",route="POST /api/query"}`,
+		`ducktape_http_requests_total{code="400",route="POST /api/query"}`,
+		`ducktape_http_request_duration_seconds_count{code="200",route="POST /api/execute"}`,
+		`ducktape_rows_returned_total`,
+		`ducktape_rows_affected_total`,
+		`ducktape_open_databases`,
+		`ducktape_duckdb_memory_bytes{database="` + databaseKey(dsn) + `"}`,
+		`ducktape_operations_in_flight{kind="query"} 0`,
+		`ducktape_statement_cache_hits_total`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(scraped, expected) {
+			t.Errorf("expected %s in the metrics", expected)
+		}
+	}
+
+	t.Run("authenticated", func(t *testing.T) {
+		authenticated := http.NewServeMux()
+		RegisterMetricsRoutes(authenticated, NewAPIKeyAuthenticator(map[string]string{"key-1": "prometheus"}))
+		for key, expected := range map[string]int{"": http.StatusUnauthorized, "wrong-key": http.StatusUnauthorized, "key-1": http.StatusOK} {
+			r := httptest.NewRequest("GET", "/metrics", nil)
+			if key != "" {
+				r.Header.Set(ducktape.APIKeyHeader, key)
+			}
+			w := httptest.NewRecorder()
+			authenticated.ServeHTTP(w, r)
+			if w.Code != expected {
+				t.Errorf("key %q: expected status %d, got %d", key, expected, w.Code)
+			}
+		}
+	})
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+)
+
+var (
+	ErrOperationNotFound  = errors.New("operation not found")
+	ErrOperationCancelled = errors.New("operation was cancelled")
+)
+
+var operations = newOperationRegistry()
+
+// operation is an in-flight query, execute or append request.
+type operation struct {
+	id            string
+	kind          string
+	dsn           string
+	sql           string
+	clientAddress string
+	startedAt     time.Time
+	rows          atomic.Int64
+	cancel        context.CancelCauseFunc
+}
+
+type operationContextKey struct{}
+
+func operationFromContext(ctx context.Context) *operation {
+	op, _ := ctx.Value(operationContextKey{}).(*operation)
+	return op
+}
+
+// addRows records progress, it is safe to call on a nil operation so helpers work outside of a request.
+func (o *operation) addRows(n int64) {
+	if o == nil {
+		return
+	}
+	o.rows.Add(n)
+}
+
+func (o *operation) toResponse() ducktape.Operation {
+	return ducktape.Operation{
+		ID:            o.id,
+		Kind:          o.kind,
+		DSN:           o.dsn,
+		SQL:           o.sql,
+		ClientAddress: o.clientAddress,
+		StartedAt:     o.startedAt,
+		Rows:          o.rows.Load(),
+	}
+}
+
+type operationRegistry struct {
+	mu         sync.Mutex
+	operations map[string]*operation
+}
+
+func newOperationRegistry() *operationRegistry {
+	return &operationRegistry{operations: make(map[string]*operation)}
+}
+
+// start registers an operation and returns a context that is cancelled when the operation is killed.
+// Callers must call [operationRegistry.finish] once the operation is done.
+func (r *operationRegistry) start(ctx context.Context, kind string, dsn string, sql string, clientAddress string) (context.Context, *operation) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	op := &operation{
+		id:            rand.Text(),
+		kind:          kind,
+		dsn:           dsn,
+		sql:           sql,
+		clientAddress: clientAddress,
+		startedAt:     time.Now(),
+		cancel:        cancel,
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.operations[op.id] = op
+	return context.WithValue(ctx, operationContextKey{}, op), op
+}
+
+func (r *operationRegistry) finish(op *operation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.operations, op.id)
+	op.cancel(nil)
+}
+
+func (r *operationRegistry) list() []ducktape.Operation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([]ducktape.Operation, 0, len(r.operations))
+	for _, op := range r.operations {
+		result = append(result, op.toResponse())
+	}
+	slices.SortFunc(result, func(a, b ducktape.Operation) int {
+		return cmp.Or(a.StartedAt.Compare(b.StartedAt), cmp.Compare(a.ID, b.ID))
+	})
+	return result
+}
+
+// cancelAll interrupts every operation with the given cause and returns how many there were.
+func (r *operationRegistry) cancelAll(cause error) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, op := range r.operations {
+		op.cancel(cause)
+	}
+	return len(r.operations)
+}
+
+// wait blocks until no operation is in flight, or until the context is done.
+func (r *operationRegistry) wait(ctx context.Context) error {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		r.mu.Lock()
+		idle := len(r.operations) == 0
+		r.mu.Unlock()
+		if idle {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// cancel interrupts an operation, DuckDB aborts the running statement once the operation's context is done.
+func (r *operationRegistry) cancel(id string) error {
+	r.mu.Lock()
+	op, ok := r.operations[id]
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrOperationNotFound, id)
+	}
+
+	op.cancel(ErrOperationCancelled)
+	return nil
+}
+
+// startOperation registers the request as an in-flight operation and advertises its ID in the response headers.
+func startOperation(ctx context.Context, w http.ResponseWriter, r *http.Request, kind string, dsn string, sql string) (context.Context, *operation) {
+	ctx, op := operations.start(ctx, kind, dsn, sql, r.RemoteAddr)
+	w.Header().Set(ducktape.DuckDBOperationIDHeader, op.id)
+	return ctx, op
+}
+
+func ListOperations() []ducktape.Operation {
+	return operations.list()
+}
+
+func CancelOperation(id string) error {
+	return operations.cancel(id)
+}
+
+func handleListOperations(w http.ResponseWriter, r *http.Request) {
+	body, err := json.Marshal(ducktape.ListOperationsResponse{Operations: ListOperations()})
+	if err != nil {
+		err := fmt.Errorf("failed to marshal the response: %v", err)
+		errMsg := err.Error()
+		handleInternalServerErrorJSON(w, ducktape.ListOperationsResponse{Error: &errMsg}, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+func handleCancelOperation(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := CancelOperation(id); err != nil {
+		errMsg := err.Error()
+		handleErrorJSON(w, ducktape.CancelOperationResponse{Error: &errMsg}, err)
+		return
+	}
+
+	body, err := json.Marshal(ducktape.CancelOperationResponse{})
+	if err != nil {
+		err := fmt.Errorf("failed to marshal the response: %v", err)
+		errMsg := err.Error()
+		handleInternalServerErrorJSON(w, ducktape.CancelOperationResponse{Error: &errMsg}, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+	LoggerFromContext(r.Context()).Info("cancelled operation", slog.String("operationId", id), slog.String("clientAddress", r.RemoteAddr))
+}
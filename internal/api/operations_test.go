@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+	_ "github.com/duckdb/duckdb-go/v2"
+)
+
+func TestOperations(t *testing.T) {
+	t.Run("list and cancel a running query", func(t *testing.T) {
+		ctx, op := operations.start(context.Background(), "query", ":memory:", "SELECT count(*) FROM range(1000000000000) a", "127.0.0.1:1234")
+		defer operations.finish(op)
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := Query(ctx, "", ducktape.QueryRequest{Query: "SELECT count(*) FROM range(1000000000000) a"})
+			done <- contextError(ctx, err)
+		}()
+
+		var listed *ducktape.Operation
+		for _, candidate := range ListOperations() {
+			if candidate.ID == op.id {
+				listed = &candidate
+			}
+		}
+		if listed == nil {
+			t.Fatalf("expected operation %q to be listed", op.id)
+		}
+		if listed.Kind != "query" || listed.DSN != ":memory:" || listed.ClientAddress != "127.0.0.1:1234" {
+			t.Errorf("unexpected operation: %+v", listed)
+		}
+
+		// Give DuckDB a moment to start executing so the cancellation interrupts a running statement
+		time.Sleep(50 * time.Millisecond)
+		if err := CancelOperation(op.id); err != nil {
+			t.Fatalf("failed to cancel operation: %v", err)
+		}
+
+		select {
+		case err := <-done:
+			if !errors.Is(err, ErrOperationCancelled) {
+				t.Errorf("expected ErrOperationCancelled, got %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the query to be interrupted")
+		}
+	})
+
+	t.Run("finished operations are removed", func(t *testing.T) {
+		ctx, op := operations.start(context.Background(), "query", "", "SELECT 1", "")
+		if _, err := Query(ctx, "", ducktape.QueryRequest{Query: "SELECT * FROM range(3)"}); err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+		if rows := op.rows.Load(); rows != 3 {
+			t.Errorf("expected 3 rows to be recorded, got %d", rows)
+		}
+		operations.finish(op)
+
+		for _, candidate := range ListOperations() {
+			if candidate.ID == op.id {
+				t.Errorf("expected operation %q to be removed", op.id)
+			}
+		}
+		if err := CancelOperation(op.id); !errors.Is(err, ErrOperationNotFound) {
+			t.Errorf("expected ErrOperationNotFound, got %v", err)
+		}
+	})
+
+	t.Run("cancelled request returns a conflict", func(t *testing.T) {
+		mux := http.NewServeMux()
+		RegisterApiRoutes(mux)
+
+		query := "SELECT count(*) FROM range(1000000000001) cancelled"
+		w := httptest.NewRecorder()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			r := httptest.NewRequest("POST", ducktape.QueryRoute, strings.NewReader(fmt.Sprintf(`{"query": %q}`, query)))
+			r.Header.Set(ducktape.DuckDBConnectionStringHeader, ":memory:")
+			mux.ServeHTTP(w, r)
+		}()
+
+		var id string
+		for deadline := time.Now().Add(5 * time.Second); id == "" && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			for _, candidate := range ListOperations() {
+				if candidate.SQL == query {
+					id = candidate.ID
+				}
+			}
+		}
+		if id == "" {
+			t.Fatal("expected the query to be listed")
+		}
+		time.Sleep(50 * time.Millisecond)
+		if err := CancelOperation(id); err != nil {
+			t.Fatalf("failed to cancel operation: %v", err)
+		}
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the query to be interrupted")
+		}
+		if w.Code != http.StatusConflict {
+			t.Errorf("expected %d, got %d: %s", http.StatusConflict, w.Code, w.Body.String())
+		}
+	})
+}
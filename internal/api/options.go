@@ -0,0 +1,73 @@
+package api
+
+import "time"
+
+// Options holds the server-wide settings of the API handlers.
+type Options struct {
+	// MaxQueryDuration caps how long a query, execute, append or explain request may run. Zero disables the limit.
+	MaxQueryDuration time.Duration
+	// MaxJobDuration caps how long an asynchronous query job may run, independently of MaxQueryDuration. Zero disables
+	// the limit, clients can still set one through the [ducktape.DuckDBTimeoutHeader] header.
+	MaxJobDuration time.Duration
+	// MaxResultRows caps the rows returned by a single query response, larger results are truncated or paginated.
+	// Zero disables the limit.
+	MaxResultRows int
+	// MaxResultBytes caps the JSON size of the rows returned by a single query response. Zero disables the limit.
+	MaxResultBytes int64
+	// JobDir is where the results of asynchronous query jobs are stored, defaults to a directory under [os.TempDir].
+	JobDir string
+	// JobResultTTL is how long the results of a finished job are kept, defaults to an hour.
+	JobResultTTL time.Duration
+	// StatementCacheSize is how many prepared statements are kept for queries, keyed by DSN and SQL text. Cached
+	// statements keep their database open between requests. Zero disables the cache.
+	StatementCacheSize int
+	// ResultCacheBytes is the memory budget of the query result cache, responses are only cached when the client asks
+	// for it through the [ducktape.DuckDBCacheTTLHeader] header. Zero disables the cache.
+	ResultCacheBytes int64
+	// ReadOnly restricts every request to SELECT statements and rejects appends.
+	ReadOnly bool
+	// ReadOnlyDSNs restricts the requests made against these databases to SELECT statements and rejects their appends.
+	// DSNs match regardless of their options, e.g. "duck.db" also covers "duck.db?threads=4".
+	ReadOnlyDSNs []string
+	// DataDir confines file databases to this directory: relative DSN paths are resolved against it and paths outside
+	// of it are rejected. SQL can then only access files under it and under JobDir, and in-memory databases are
+	// rejected unless AllowInMemory is set. Empty leaves DSNs unrestricted.
+	DataDir string
+	// AllowInMemory lets clients open in-memory databases while DataDir is set.
+	AllowInMemory bool
+	// AllowedDSNOptions lists the DSN options clients may set, e.g. "threads". Nil allows every option that is not denied.
+	AllowedDSNOptions []string
+	// DeniedDSNOptions lists the DSN options clients may never set, e.g. "allow_unsigned_extensions".
+	DeniedDSNOptions []string
+	// DSNAliases maps names that clients can use as their DSN to the DSN they stand for, e.g. "analytics" to
+	// "analytics.db?threads=4". Aliases are trusted and skip the checks above, relative paths are still resolved
+	// against DataDir.
+	DSNAliases map[string]string
+	// DSNDefaults are DuckDB options added to every DSN that does not set them, e.g. "threads" to "4". They are set by
+	// the server, so they are not subject to AllowedDSNOptions and DeniedDSNOptions.
+	DSNDefaults map[string]string
+	// MaxBodyBytes caps the size of request bodies, larger requests are rejected with a 413. Appends are streamed, so
+	// they are not subject to it. Zero disables the limit.
+	MaxBodyBytes int64
+	// RouteMaxBodyBytes overrides MaxBodyBytes for some routes, keyed by their path, e.g. [ducktape.AppendRoute] to
+	// allow larger uploads. Zero disables the limit of the route.
+	RouteMaxBodyBytes map[string]int64
+	// HealthCheckDSNs lists the databases that readiness probes open and query, on top of every DSN in DSNAliases.
+	// Readiness also fails while the server drains and when the directory of file databases (DataDir or the working
+	// directory) is not writable or is low on space.
+	HealthCheckDSNs []string
+	// HealthMinFreeBytes is the free space that the directory of file databases must have for the server to be ready.
+	// Zero disables the check of the free space.
+	HealthMinFreeBytes int64
+	// HealthCheckTimeout bounds the checks of a readiness probe, defaults to 5 seconds.
+	HealthCheckTimeout time.Duration
+	// Policy restricts what each authenticated principal can do, see [LoadPolicy]. Nil lets every caller do anything.
+	Policy *Policy
+}
+
+var options Options
+
+// Configure applies server-wide settings, it must be called before the routes start serving requests.
+func Configure(o Options) {
+	options = o
+}
@@ -1,14 +1,12 @@
 package api
 
 import (
-	"database/sql"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/artie-labs/ducktape/api/pkg/ducktape"
-	_ "github.com/duckdb/duckdb-go/v2"
 )
 
 func handlePing(w http.ResponseWriter, r *http.Request) {
@@ -23,10 +21,10 @@ func handlePing(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 
-	db, err := sql.Open("duckdb", dsn)
+	db, err := openDB(ctx, dsn, "ping")
 	if err != nil {
 		errMsg := err.Error()
-		handleInternalServerErrorJSON(w, ducktape.QueryResponse{Error: &errMsg}, err)
+		handleErrorJSON(w, ducktape.QueryResponse{Error: &errMsg}, err)
 		return
 	}
 	defer db.Close()
@@ -40,5 +38,5 @@ func handlePing(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusOK)
 
-	slog.Debug("ping result", slog.Duration("elapsed", time.Since(start)))
+	LoggerFromContext(r.Context()).Debug("ping result", slog.Duration("elapsed", time.Since(start)))
 }
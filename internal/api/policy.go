@@ -0,0 +1,177 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+)
+
+// ErrForbidden is returned when the policy does not let the caller make the request.
+var ErrForbidden = errors.New("forbidden")
+
+// Operation is what a request does, as far as policies are concerned.
+type Operation string
+
+const (
+	// OperationQuery covers queries, explains, jobs and beginning transactions. Queries can run any statement, so the
+	// requests of principals that are not allowed [OperationExecute] as well are put in read-only mode.
+	OperationQuery Operation = "query"
+	// OperationExecute covers executes and committing or rolling back transactions.
+	OperationExecute Operation = "execute"
+	OperationAppend  Operation = "append"
+	OperationPing    Operation = "ping"
+	// OperationAdmin covers the /api/admin routes.
+	OperationAdmin Operation = "admin"
+)
+
+// anyPrincipal is the policy entry that applies to the principals that have no entry of their own.
+const anyPrincipal = "*"
+
+// Policy maps principals to what they are allowed to do. Principals without an entry, including unauthenticated
+// callers, fall back to the "*" entry if there is one and are denied otherwise.
+type Policy struct {
+	Principals map[string]PrincipalPolicy `yaml:"principals" json:"principals"`
+}
+
+type PrincipalPolicy struct {
+	// DSNs lists the DSNs, aliases or glob patterns (e.g. "reports/*.db") the principal can open, "*" allows any DSN.
+	DSNs []string `yaml:"dsns" json:"dsns"`
+	// Operations lists the operations the principal can make.
+	Operations []Operation `yaml:"operations" json:"operations"`
+	// ReadOnly puts every request of the principal in read-only mode, see [WithReadOnly].
+	ReadOnly bool `yaml:"readOnly" json:"readOnly"`
+}
+
+// LoadPolicy reads a policy file, in YAML or JSON.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the policy file: %w", err)
+	}
+	var policy Policy
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("failed to parse the policy file: %w", err)
+	}
+	if err := policy.validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy file: %w", err)
+	}
+	return &policy, nil
+}
+
+func (p *Policy) validate() error {
+	for name, principal := range p.Principals {
+		for _, operation := range principal.Operations {
+			switch operation {
+			case OperationQuery, OperationExecute, OperationAppend, OperationPing, OperationAdmin:
+			default:
+				return fmt.Errorf("principal %q: unknown operation %q", name, operation)
+			}
+		}
+		for _, pattern := range principal.DSNs {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("principal %q: invalid DSN pattern %q: %w", name, pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// authorize checks that the policy lets the principal make the operation against the DSN. An empty DSN is not
+// checked: requests without one refer to a transaction, cursor or job by its ID, which was only handed out to a
+// request that was authorized against its DSN, and [checkOwner] restricts it to the principal of that request.
+func (p *Policy) authorize(principal Principal, operation Operation, dsn string) (PrincipalPolicy, error) {
+	entry, ok := p.Principals[principal.Name]
+	if !ok || principal.Name == "" {
+		if entry, ok = p.Principals[anyPrincipal]; !ok {
+			return PrincipalPolicy{}, fmt.Errorf("%w: no policy for principal %q", ErrForbidden, principal.Name)
+		}
+	}
+	if !slices.Contains(entry.Operations, operation) {
+		return PrincipalPolicy{}, fmt.Errorf("%w: operation %q is not allowed", ErrForbidden, operation)
+	}
+	if dsn != "" && !slices.ContainsFunc(entry.DSNs, func(pattern string) bool { return matchDSN(pattern, dsn) }) {
+		return PrincipalPolicy{}, fmt.Errorf("%w: DSN %q is not allowed", ErrForbidden, dsnPath(dsn))
+	}
+	return entry, nil
+}
+
+// checkOwner returns [ErrForbidden] when the caller is authenticated as another principal than owner, the principal
+// that started a transaction, cursor or job. Contexts without a principal, such as those of Go code calling this
+// package directly, are not checked.
+func checkOwner(ctx context.Context, owner string) error {
+	principal, ok := PrincipalFromContext(ctx)
+	if ok && principal.Name != owner {
+		return fmt.Errorf("%w: it belongs to another principal", ErrForbidden)
+	}
+	return nil
+}
+
+// principalName returns the name of the caller, empty when the context has no principal.
+func principalName(ctx context.Context) string {
+	principal, _ := PrincipalFromContext(ctx)
+	return principal.Name
+}
+
+// matchDSN matches the DSN a client sent against a policy entry, regardless of the DSN options. Entries without glob
+// characters match the same database however its path is spelled.
+func matchDSN(pattern string, dsn string) bool {
+	if pattern == "*" {
+		return true
+	}
+	path, _, _ := strings.Cut(dsn, "?")
+	if !strings.ContainsAny(pattern, "*?[") {
+		return pattern == path || databaseKey(pattern) == databaseKey(dsn)
+	}
+	if matched, _ := filepath.Match(pattern, path); matched {
+		return true
+	}
+	matched, _ := filepath.Match(databaseKey(pattern), databaseKey(dsn))
+	return matched
+}
+
+// authorized wraps a handler with the [Options.Policy] checks for its operation, made before the handler opens any
+// database. Denied requests are logged and rejected with 403 Forbidden. Principals whose policy is read-only get their
+// requests put in read-only mode, and so do the queries of principals that cannot execute.
+func authorized(operation Operation, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if options.Policy == nil {
+			handler(w, r)
+			return
+		}
+
+		principal, _ := PrincipalFromContext(r.Context())
+		dsn := r.Header.Get(ducktape.DuckDBConnectionStringHeader)
+		entry, err := options.Policy.authorize(principal, operation, dsn)
+		if err != nil {
+			LoggerFromContext(r.Context()).Warn("denied request",
+				slog.String("principal", principal.Name),
+				slog.String("operation", string(operation)),
+				slog.String("dsn", dsnPath(dsn)),
+				slog.String("route", r.Pattern),
+				slog.String("clientAddress", r.RemoteAddr),
+				slog.Any("error", err),
+			)
+			errMsg := err.Error()
+			writeErrorJSON(w, http.StatusForbidden, ducktape.ErrorResponse{Error: &errMsg}, err)
+			return
+		}
+
+		if entry.ReadOnly || (operation == OperationQuery && !slices.Contains(entry.Operations, OperationExecute)) {
+			r = r.WithContext(WithReadOnly(r.Context()))
+		}
+		handler(w, r)
+	}
+}
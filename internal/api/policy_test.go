@@ -0,0 +1,155 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+)
+
+func TestLoadPolicy(t *testing.T) {
+	write := func(content string) string {
+		path := filepath.Join(t.TempDir(), "policy.yaml")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write policy: %v", err)
+		}
+		return path
+	}
+
+	policy, err := LoadPolicy(write(`
+principals:
+  etl:
+    dsns: [analytics, "staging/*.db"]
+    operations: [query, execute, append]
+  dashboards:
+    dsns: [analytics]
+    operations: [query]
+    readOnly: true
+`))
+	if err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+	if entry := policy.Principals["dashboards"]; !entry.ReadOnly || len(entry.Operations) != 1 {
+		t.Errorf("unexpected policy for dashboards: %+v", entry)
+	}
+
+	for name, content := range map[string]string{
+		"unknown operation": "principals:\n  etl:\n    operations: [drop]\n",
+		"unknown field":     "principals:\n  etl:\n    operation: [query]\n",
+		"invalid pattern":   "principals:\n  etl:\n    dsns: [\"[\"]\n",
+	} {
+		if _, err := LoadPolicy(write(content)); err == nil {
+			t.Errorf("%s: expected the policy to be rejected", name)
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	dataDir := t.TempDir()
+	Configure(Options{DataDir: dataDir, DSNAliases: map[string]string{"analytics": "analytics.db"}})
+	t.Cleanup(func() { Configure(Options{}) })
+
+	policy := &Policy{Principals: map[string]PrincipalPolicy{
+		"etl": {DSNs: []string{"analytics", "staging/*.db"}, Operations: []Operation{OperationQuery, OperationExecute}},
+		"*":   {DSNs: []string{"*"}, Operations: []Operation{OperationPing}},
+	}}
+	etl := Principal{Name: "etl", Method: "api-key"}
+
+	for _, tc := range []struct {
+		principal Principal
+		operation Operation
+		dsn       string
+	}{
+		{etl, OperationQuery, "analytics"},
+		{etl, OperationQuery, "analytics.db?threads=4"},
+		{etl, OperationExecute, filepath.Join(dataDir, "analytics.db")},
+		{etl, OperationQuery, "staging/orders.db"},
+		{etl, OperationQuery, ""},
+		{Principal{Name: "other"}, OperationPing, "anything.db"},
+		{Principal{}, OperationPing, "anything.db"},
+	} {
+		if _, err := policy.authorize(tc.principal, tc.operation, tc.dsn); err != nil {
+			t.Errorf("expected %q to be allowed to %s %q, got %v", tc.principal.Name, tc.operation, tc.dsn, err)
+		}
+	}
+
+	for _, tc := range []struct {
+		principal Principal
+		operation Operation
+		dsn       string
+	}{
+		{etl, OperationAppend, "analytics"},
+		{etl, OperationPing, "analytics"},
+		{etl, OperationQuery, "other.db"},
+		{etl, OperationQuery, "staging/nested/orders.db"},
+		{Principal{Name: "other"}, OperationQuery, "analytics"},
+	} {
+		if _, err := policy.authorize(tc.principal, tc.operation, tc.dsn); !errors.Is(err, ErrForbidden) {
+			t.Errorf("expected %q not to be allowed to %s %q, got %v", tc.principal.Name, tc.operation, tc.dsn, err)
+		}
+	}
+
+	if _, err := (&Policy{}).authorize(etl, OperationQuery, "analytics"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("expected principals without a policy to be denied, got %v", err)
+	}
+}
+
+func TestAuthorized(t *testing.T) {
+	t.Cleanup(func() { Configure(Options{}) })
+	handler := authorized(OperationQuery, func(w http.ResponseWriter, r *http.Request) {
+		if isReadOnly(r.Context(), "") {
+			w.Write([]byte("read-only"))
+		} else {
+			w.Write([]byte("read-write"))
+		}
+	})
+	serve := func(name string, dsn string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", ducktape.QueryRoute, nil)
+		r.Header.Set(ducktape.DuckDBConnectionStringHeader, dsn)
+		if name != "" {
+			r = r.WithContext(WithPrincipal(r.Context(), Principal{Name: name, Method: "api-key"}))
+		}
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	Configure(Options{})
+	if w := serve("", "duck.db"); w.Code != http.StatusOK || w.Body.String() != "read-write" {
+		t.Errorf("expected every request to be allowed without a policy, got %d with %q", w.Code, w.Body.String())
+	}
+
+	Configure(Options{Policy: &Policy{Principals: map[string]PrincipalPolicy{
+		"etl":        {DSNs: []string{"duck.db"}, Operations: []Operation{OperationQuery, OperationExecute}},
+		"dashboards": {DSNs: []string{"duck.db"}, Operations: []Operation{OperationQuery, OperationExecute}, ReadOnly: true},
+		"reports":    {DSNs: []string{"duck.db"}, Operations: []Operation{OperationQuery}},
+	}}})
+	if w := serve("etl", "duck.db"); w.Code != http.StatusOK || w.Body.String() != "read-write" {
+		t.Errorf("expected etl to be allowed, got %d with %q", w.Code, w.Body.String())
+	}
+	if w := serve("dashboards", "duck.db"); w.Code != http.StatusOK || w.Body.String() != "read-only" {
+		t.Errorf("expected dashboards to be put in read-only mode, got %d with %q", w.Code, w.Body.String())
+	}
+	if w := serve("reports", "duck.db"); w.Code != http.StatusOK || w.Body.String() != "read-only" {
+		t.Errorf("expected the queries of reports to be read-only without execute, got %d with %q", w.Code, w.Body.String())
+	}
+	if w := serve("etl", "other.db?s3_secret_access_key=secret"); strings.Contains(w.Body.String(), "secret") {
+		t.Errorf("expected the DSN options to be left out of the error, got %q", w.Body.String())
+	}
+	for name, dsn := range map[string]string{"etl": "other.db", "": "duck.db"} {
+		w := serve(name, dsn)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("expected %q to be denied %q, got %d", name, dsn, w.Code)
+			continue
+		}
+		var response ducktape.ErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.Error == nil {
+			t.Errorf("expected an error response, got %q", w.Body.String())
+		}
+	}
+}
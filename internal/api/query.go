@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -10,37 +11,134 @@ import (
 
 	"github.com/artie-labs/ducktape/api/pkg/ducktape"
 	"github.com/artie-labs/ducktape/internal/utils"
-	_ "github.com/duckdb/duckdb-go/v2"
 )
 
 func handleQuery(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 	dsn := r.Header.Get(ducktape.DuckDBConnectionStringHeader)
-	if dsn == "" {
+	transactionID := r.Header.Get(ducktape.DuckDBTransactionIDHeader)
+
+	request, err := getRequestBody[ducktape.QueryRequest](r)
+	if err != nil {
+		errMsg := err.Error()
+		handleRequestBodyErrorJSON(w, ducktape.QueryResponse{Error: &errMsg}, err)
+		return
+	}
+	if dsn == "" && transactionID == "" && request.Cursor == "" {
 		err := fmt.Errorf("%q header is required", ducktape.DuckDBConnectionStringHeader)
 		errMsg := err.Error()
 		handleBadRequestJSON(w, ducktape.QueryResponse{Error: &errMsg}, err)
 		return
 	}
+	if transactionID != "" && (request.PageSize != 0 || request.Cursor != "") {
+		err := fmt.Errorf("pagination is not supported inside a transaction")
+		errMsg := err.Error()
+		handleBadRequestJSON(w, ducktape.QueryResponse{Error: &errMsg}, err)
+		return
+	}
+	ctx, cancel, err := withQueryTimeout(r)
+	if err != nil {
+		errMsg := err.Error()
+		handleBadRequestJSON(w, ducktape.QueryResponse{Error: &errMsg}, err)
+		return
+	}
+	defer cancel()
 
-	request, err := getRequestBody[ducktape.QueryRequest](r)
+	ctx, err = withReadOnlyHeader(ctx, r)
 	if err != nil {
 		errMsg := err.Error()
 		handleBadRequestJSON(w, ducktape.QueryResponse{Error: &errMsg}, err)
 		return
 	}
-	ctx := r.Context()
 
-	objects, err := Query(ctx, dsn, request)
+	cacheTTL, err := resultCacheTTL(r)
 	if err != nil {
 		errMsg := err.Error()
-		handleInternalServerErrorJSON(w, ducktape.QueryResponse{Error: &errMsg}, err)
+		handleBadRequestJSON(w, ducktape.QueryResponse{Error: &errMsg}, err)
+		return
+	}
+	var cacheKey string
+	var cacheVersion uint64
+	cacheable := cacheTTL > 0 && transactionID == "" && request.Cursor == "" && request.PageSize == 0 && !request.DryRun && results.enabled(dsn)
+	if cacheable {
+		cacheKey, err = resultKey(dsn, request)
+		if err != nil {
+			errMsg := err.Error()
+			handleBadRequestJSON(w, ducktape.QueryResponse{Error: &errMsg}, err)
+			return
+		}
+		if body, ok := results.get(dsn, cacheKey); ok {
+			w.Header().Set(ducktape.DuckDBCacheHeader, "hit")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write(body)
+			LoggerFromContext(r.Context()).Debug("cached query results", slog.Duration("elapsed", time.Since(start)))
+			return
+		}
+		w.Header().Set(ducktape.DuckDBCacheHeader, "miss")
+		cacheVersion = results.version(dsn)
+	}
+
+	ctx, operation := startOperation(ctx, w, r, "query", dsn, request.Query)
+	defer operations.finish(operation)
+
+	if cacheable {
+		// Only single SELECT statements are safe to cache
+		cacheable, err = isSingleSelect(ctx, request.Query)
+		if err != nil {
+			err = contextError(ctx, err)
+			errMsg := err.Error()
+			handleErrorJSON(w, ducktape.QueryResponse{Error: &errMsg}, err)
+			return
+		}
+	}
+
+	if request.DryRun {
+		if transactionID != "" {
+			err := fmt.Errorf("dry run is not supported inside a transaction")
+			errMsg := err.Error()
+			handleBadRequestJSON(w, ducktape.QueryResponse{Error: &errMsg}, err)
+			return
+		}
+
+		validation, err := Validate(ctx, dsn, []ducktape.ExecuteStatement{{Query: request.Query, Args: request.Args, NamedArgs: request.NamedArgs}})
+		if err != nil {
+			err = contextError(ctx, err)
+			errMsg := err.Error()
+			handleErrorJSON(w, ducktape.QueryResponse{Error: &errMsg}, err)
+			return
+		}
+
+		body, err := json.Marshal(ducktape.QueryResponse{Validation: validation})
+		if err != nil {
+			err := fmt.Errorf("failed to marshal the response: %v", err)
+			errMsg := err.Error()
+			handleInternalServerErrorJSON(w, ducktape.QueryResponse{Error: &errMsg}, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+		LoggerFromContext(r.Context()).Debug("dry run results", slog.Any("validation", validation), slog.Duration("elapsed", time.Since(start)))
 		return
 	}
 
-	response := ducktape.QueryResponse{
-		Rows: objects,
+	var response ducktape.QueryResponse
+	switch {
+	case transactionID != "":
+		response, err = QueryInTransaction(ctx, transactionID, dsn, request)
+	case request.Cursor != "":
+		response, err = FetchPage(ctx, request.Cursor, dsn)
+	default:
+		response, err = QueryPage(ctx, dsn, request)
 	}
+	if err != nil {
+		err = contextError(ctx, err)
+		errMsg := err.Error()
+		handleErrorJSON(w, ducktape.QueryResponse{Error: &errMsg}, err)
+		return
+	}
+
 	body, err := json.Marshal(response)
 	if err != nil {
 		err := fmt.Errorf("failed to marshal the response: %v", err)
@@ -48,40 +146,168 @@ func handleQuery(w http.ResponseWriter, r *http.Request) {
 		handleInternalServerErrorJSON(w, ducktape.QueryResponse{Error: &errMsg}, err)
 		return
 	}
+	if cacheable {
+		results.put(dsn, cacheKey, cacheVersion, body, cacheTTL)
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(body)
-	slog.Debug("query results", slog.Any("rows", objects), slog.Duration("elapsed", time.Since(start)))
+	LoggerFromContext(r.Context()).Debug("query results", slog.Any("rows", response.Rows), slog.Bool("truncated", response.Truncated), slog.Duration("elapsed", time.Since(start)))
 }
 
+// ErrResultTruncated is returned by [Query] along with the rows that fit when the result hits [Options.MaxResultRows]
+// or [Options.MaxResultBytes].
+var ErrResultTruncated = errors.New("result was truncated by the server's result limits")
+
+// Query runs the query and returns its rows. When the result exceeds the server-wide result limits, the rows that fit
+// are returned along with [ErrResultTruncated], use [QueryPage] to page through the whole result instead.
 func Query(ctx context.Context, dsn string, request ducktape.QueryRequest) ([]map[string]any, error) {
-	db, err := sql.Open("duckdb", dsn)
+	response, err := QueryPage(ctx, dsn, request)
 	if err != nil {
-		return nil, fmt.Errorf("failed to start a SQL client for queries(%q): %w", "duckdb", err)
+		return nil, err
 	}
-	defer db.Close()
+	if response.Truncated {
+		return response.Rows, fmt.Errorf("%w: only %d rows were returned", ErrResultTruncated, len(response.Rows))
+	}
+	return response.Rows, nil
+}
 
-	if err = db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to validate the DB connection for queries(%q): %w", "duckdb", err)
+// QueryPage runs the query and returns its rows. Without a page size every row is buffered, up to the server-wide
+// [Options.MaxResultRows] and [Options.MaxResultBytes], and the response is flagged as truncated when a limit is hit.
+// With a page size the first page is returned along with a cursor to fetch the rest through [FetchPage].
+func QueryPage(ctx context.Context, dsn string, request ducktape.QueryRequest) (ducktape.QueryResponse, error) {
+	if request.PageSize < 0 {
+		return ducktape.QueryResponse{}, fmt.Errorf("page size must not be negative, got %d", request.PageSize)
+	}
+	if err := checkReadOnly(ctx, dsn, request.Query); err != nil {
+		return ducktape.QueryResponse{}, err
+	}
+	if results.enabled(dsn) {
+		selectOnly, err := isSelectOnly(ctx, request.Query)
+		if err != nil {
+			return ducktape.QueryResponse{}, fmt.Errorf("failed to classify the statement: %w", err)
+		}
+		if !selectOnly {
+			// Writes such as INSERT ... RETURNING can run as queries too. Runs last, once the statement has completed.
+			defer results.invalidate(dsn)
+		}
+	}
+	if request.PageSize > 0 {
+		return cursors.open(ctx, dsn, request)
 	}
 
+	if statements.enabled(dsn) {
+		response, err := queryObjects(ctx, cachedQueryer{dsn: dsn}, request)
+		if !errors.Is(err, errStatementNotCacheable) {
+			return response, err
+		}
+	}
+
+	db, err := openDB(ctx, dsn, "queries")
+	if err != nil {
+		return ducktape.QueryResponse{}, err
+	}
+	defer db.Close()
+
 	conn, err := db.Conn(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get a connection for queries(%q): %w", "duckdb", err)
+		return ducktape.QueryResponse{}, fmt.Errorf("failed to get a connection for queries(%q): %w", "duckdb", err)
 	}
 	defer conn.Close()
 
-	slog.Debug("querying duckdb", slog.String("query", request.Query), slog.Any("args", request.Args))
+	return queryObjects(ctx, conn, request)
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func queryObjects(ctx context.Context, q queryer, request ducktape.QueryRequest) (response ducktape.QueryResponse, err error) {
+	ctx, span := startSpan(ctx, "duckdb.query", queryText(request.Query))
+	defer func() { endSpan(span, err) }()
 
-	rows, err := conn.QueryContext(ctx, request.Query, request.Args...)
+	LoggerFromContext(ctx).Debug("querying duckdb", slog.String("query", request.Query), slog.Any("args", request.Args), slog.Any("namedArgs", request.NamedArgs))
+
+	query, args, err := bindArgs(request.Query, request.Args, request.NamedArgs)
+	if err != nil {
+		return ducktape.QueryResponse{}, err
+	}
+	rows, err := q.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query the DB: %w", err)
+		return ducktape.QueryResponse{}, fmt.Errorf("failed to query the DB: %w", err)
 	}
 	defer rows.Close()
 
-	objects, err := utils.RowsToObjects(rows)
+	columns, err := rows.Columns()
 	if err != nil {
-		return nil, fmt.Errorf("failed to convert rows to objects: %w", err)
+		return ducktape.QueryResponse{}, fmt.Errorf("failed to convert rows to objects: %w", err)
+	}
+
+	page, err := readPage(ctx, rows, columns, nil, options.MaxResultRows, options.MaxResultBytes)
+	if err != nil {
+		return ducktape.QueryResponse{}, err
+	}
+	return ducktape.QueryResponse{Rows: page.objects, Truncated: page.next != nil}, nil
+}
+
+type page struct {
+	objects []map[string]any
+	// next is the first row of the following page, nil once the result set is exhausted.
+	next map[string]any
+}
+
+// readPage scans rows until the result set is exhausted or a limit is reached, a limit of zero means unlimited.
+// pending is a row scanned by a previous call that did not fit in the previous page.
+// The first row of a page is always included, even when it alone exceeds maxBytes, so every page makes progress.
+func readPage(ctx context.Context, rows *sql.Rows, columns []string, pending map[string]any, maxRows int, maxBytes int64) (page, error) {
+	op := operationFromContext(ctx)
+
+	var result page
+	var size int64
+	add := func(object map[string]any) (bool, error) {
+		if maxRows > 0 && len(result.objects) >= maxRows {
+			return false, nil
+		}
+		if maxBytes > 0 {
+			encoded, err := json.Marshal(object)
+			if err != nil {
+				return false, fmt.Errorf("failed to measure the row size: %w", err)
+			}
+			if len(result.objects) > 0 && size+int64(len(encoded)) > maxBytes {
+				return false, nil
+			}
+			size += int64(len(encoded))
+		}
+		result.objects = append(result.objects, object)
+		return true, nil
+	}
+
+	if pending != nil {
+		if _, err := add(pending); err != nil {
+			return page{}, err
+		}
+	}
+
+	for rows.Next() {
+		object, err := utils.ScanObject(rows, columns)
+		if err != nil {
+			return page{}, fmt.Errorf("failed to convert rows to objects: %w", err)
+		}
+		op.addRows(1)
+		rowsReturnedTotal.Inc()
+
+		added, err := add(object)
+		if err != nil {
+			return page{}, err
+		}
+		if !added {
+			result.next = object
+			return result, nil
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return page{}, fmt.Errorf("failed to convert rows to objects: failed to iterate over rows: %w", err)
 	}
-	return objects, nil
+	return result, nil
 }
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+)
+
+// ErrReadOnly is returned when a request that is in read-only mode would write.
+var ErrReadOnly = errors.New("not allowed in read-only mode")
+
+type readOnlyContextKey struct{}
+
+// WithReadOnly returns a context in which queries, executes and explains only accept SELECT statements and appends are
+// rejected. [Options.ReadOnly] and [Options.ReadOnlyDSNs] enforce the same for every request.
+func WithReadOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readOnlyContextKey{}, true)
+}
+
+// withReadOnlyHeader applies the [ducktape.DuckDBReadOnlyHeader] header, which can only turn read-only mode on.
+func withReadOnlyHeader(ctx context.Context, r *http.Request) (context.Context, error) {
+	header := r.Header.Get(ducktape.DuckDBReadOnlyHeader)
+	if header == "" {
+		return ctx, nil
+	}
+	readOnly, err := strconv.ParseBool(header)
+	if err != nil {
+		return ctx, fmt.Errorf("failed to parse the %q header: %w", ducktape.DuckDBReadOnlyHeader, err)
+	}
+	if readOnly {
+		ctx = WithReadOnly(ctx)
+	}
+	return ctx, nil
+}
+
+func isReadOnly(ctx context.Context, dsn string) bool {
+	if options.ReadOnly {
+		return true
+	}
+	if readOnly, _ := ctx.Value(readOnlyContextKey{}).(bool); readOnly {
+		return true
+	}
+	database := databaseKey(dsn)
+	return slices.ContainsFunc(options.ReadOnlyDSNs, func(readOnlyDSN string) bool {
+		return databaseKey(readOnlyDSN) == database
+	})
+}
+
+// checkReadOnly returns [ErrReadOnly] when the request is in read-only mode and one of the queries is not a SELECT.
+//
+// Statements are classified by DuckDB's parser instead of opening the database with access_mode=READ_ONLY: DuckDB
+// shares one instance per file within a process and refuses to open it again with a different access mode, so a
+// read-only request could not run while a read-write handle to the same file is open.
+func checkReadOnly(ctx context.Context, dsn string, queries ...string) error {
+	if !isReadOnly(ctx, dsn) {
+		return nil
+	}
+	for _, query := range queries {
+		selectOnly, err := isSelectOnly(ctx, query)
+		if err != nil {
+			return fmt.Errorf("failed to classify the statement: %w", err)
+		}
+		if !selectOnly {
+			return fmt.Errorf("%w: only SELECT statements can run, got %q", ErrReadOnly, query)
+		}
+	}
+	return nil
+}
+
+// parserDB is a private in-memory database used to parse statements, it never holds any data.
+var parserDB = sync.OnceValues(func() (*sql.DB, error) {
+	return openScratchDB("statement parser")
+})
+
+type serializedStatements struct {
+	Error      bool  `json:"error"`
+	Statements []any `json:"statements"`
+}
+
+// isSelectOnly reports whether every statement of the query is a SELECT. DuckDB can only serialize SELECT statements
+// to JSON, so json_serialize_sql parses the whole query, without binding it, and fails for any other statement.
+// Queries that do not parse are reported as not SELECT-only as well: the regular path would fail on them anyway, and
+// they could otherwise smuggle statements into queries that are wrapped by the server, such as the COPY of a job.
+func isSelectOnly(ctx context.Context, query string) (bool, error) {
+	statements, err := serializeStatements(ctx, query)
+	if err != nil {
+		return false, err
+	}
+	return !statements.Error, nil
+}
+
+// isSingleSelect reports whether the query is exactly one SELECT statement.
+func isSingleSelect(ctx context.Context, query string) (bool, error) {
+	statements, err := serializeStatements(ctx, query)
+	if err != nil {
+		return false, err
+	}
+	return !statements.Error && len(statements.Statements) == 1, nil
+}
+
+func serializeStatements(ctx context.Context, query string) (serializedStatements, error) {
+	db, err := parserDB()
+	if err != nil {
+		return serializedStatements{}, err
+	}
+
+	var serialized string
+	if err := db.QueryRowContext(ctx, "SELECT json_serialize_sql(?::VARCHAR)::VARCHAR", query).Scan(&serialized); err != nil {
+		return serializedStatements{}, fmt.Errorf("failed to parse the query: %w", err)
+	}
+	var statements serializedStatements
+	if err := json.Unmarshal([]byte(serialized), &statements); err != nil {
+		return serializedStatements{}, fmt.Errorf("failed to unmarshal the parsed query: %w", err)
+	}
+	return statements, nil
+}
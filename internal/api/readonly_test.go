@@ -0,0 +1,145 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+	_ "github.com/duckdb/duckdb-go/v2"
+)
+
+func TestIsSelectOnly(t *testing.T) {
+	ctx := context.Background()
+	for query, expected := range map[string]bool{
+		"SELECT 1":                                 true,
+		"FROM test WHERE id = $id":                 true,
+		"WITH a AS (SELECT 1) SELECT * FROM a":     true,
+		"SELECT 1; SELECT 2":                       true,
+		"DESCRIBE test":                            true,
+		"DROP TABLE test":                          false,
+		"SELECT 1; DELETE FROM test":               false,
+		"INSERT INTO test VALUES (1)":              false,
+		"EXPLAIN ANALYZE DELETE FROM test":         false,
+		"ATTACH 'other.db'":                        false,
+		"COPY test TO 'test.csv'":                  false,
+		"SELECT 1) TO 'x.csv'; DROP TABLE test --": false,
+	} {
+		actual, err := isSelectOnly(ctx, query)
+		if err != nil {
+			t.Fatalf("isSelectOnly(%q): %v", query, err)
+		}
+		if actual != expected {
+			t.Errorf("isSelectOnly(%q): expected %t, got %t", query, expected, actual)
+		}
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	ctx := context.Background()
+	dsn := "test_read_only.db"
+	t.Cleanup(func() {
+		Configure(Options{})
+		os.Remove(dsn)
+	})
+
+	_, err := Execute(ctx, dsn, ducktape.ExecuteRequest{
+		Statements: []ducktape.ExecuteStatement{
+			{Query: `CREATE TABLE test_read_only AS SELECT range AS id FROM range(3)`},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	readOnlyCtx := WithReadOnly(ctx)
+	drop := ducktape.QueryRequest{Query: "DROP TABLE test_read_only"}
+
+	t.Run("selects are allowed", func(t *testing.T) {
+		rows, err := Query(readOnlyCtx, dsn, ducktape.QueryRequest{Query: "SELECT * FROM test_read_only"})
+		if err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+		if len(rows) != 3 {
+			t.Errorf("expected 3 rows, got %d", len(rows))
+		}
+	})
+
+	t.Run("writes are rejected", func(t *testing.T) {
+		if _, err := Query(readOnlyCtx, dsn, drop); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("expected a read-only error for a query, got %v", err)
+		}
+		_, err := Execute(readOnlyCtx, dsn, ducktape.ExecuteRequest{
+			Statements: []ducktape.ExecuteStatement{{Query: "INSERT INTO test_read_only VALUES (3)"}},
+		})
+		if !errors.Is(err, ErrReadOnly) {
+			t.Errorf("expected a read-only error for an execute, got %v", err)
+		}
+		_, _, err = Append(readOnlyCtx, dsn, "test_read_only", "main", "test_read_only", strings.NewReader(`{"id": 3}`))
+		if !errors.Is(err, ErrReadOnly) {
+			t.Errorf("expected a read-only error for an append, got %v", err)
+		}
+		_, err = Explain(readOnlyCtx, dsn, ducktape.ExplainRequest{Query: "DELETE FROM test_read_only", Analyze: true})
+		if !errors.Is(err, ErrReadOnly) {
+			t.Errorf("expected a read-only error for explain analyze, got %v", err)
+		}
+	})
+
+	t.Run("inside a transaction", func(t *testing.T) {
+		transactionID, err := BeginTransaction(dsn)
+		if err != nil {
+			t.Fatalf("failed to begin: %v", err)
+		}
+		defer RollbackTransaction(transactionID, dsn)
+
+		if _, err := QueryInTransaction(readOnlyCtx, transactionID, dsn, drop); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("expected a read-only error, got %v", err)
+		}
+		if _, err := QueryInTransaction(readOnlyCtx, transactionID, dsn, ducktape.QueryRequest{Query: "SELECT 1"}); err != nil {
+			t.Errorf("failed to query: %v", err)
+		}
+	})
+
+	t.Run("per DSN", func(t *testing.T) {
+		Configure(Options{ReadOnlyDSNs: []string{"./" + dsn}})
+		defer Configure(Options{})
+
+		if _, err := Query(ctx, dsn+"?threads=1", drop); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("expected a read-only error, got %v", err)
+		}
+		if _, err := Query(ctx, ":memory:", ducktape.QueryRequest{Query: "CREATE TABLE other (id INTEGER)"}); err != nil {
+			t.Errorf("expected other DSNs to stay writable, got %v", err)
+		}
+	})
+
+	t.Run("server-wide", func(t *testing.T) {
+		Configure(Options{ReadOnly: true})
+		defer Configure(Options{})
+
+		if _, err := SubmitJob(dsn, drop, 0); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("expected a read-only error for a job, got %v", err)
+		}
+	})
+
+	t.Run("header", func(t *testing.T) {
+		r := httptest.NewRequest("POST", ducktape.QueryRoute, strings.NewReader(`{"query": "DROP TABLE test_read_only"}`))
+		r.Header.Set(ducktape.DuckDBConnectionStringHeader, dsn)
+		r.Header.Set(ducktape.DuckDBReadOnlyHeader, "true")
+		w := httptest.NewRecorder()
+		handleQuery(w, r)
+		if w.Code != 403 {
+			t.Errorf("expected status 403, got %d: %s", w.Code, w.Body.String())
+		}
+
+		rows, err := Query(ctx, dsn, ducktape.QueryRequest{Query: "SELECT count(*) AS count FROM test_read_only"})
+		if err != nil {
+			t.Fatalf("expected the table to still exist: %v", err)
+		}
+		if rows[0]["count"] != int64(3) {
+			t.Errorf("expected 3 rows, got %v", rows[0]["count"])
+		}
+	})
+}
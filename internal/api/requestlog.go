@@ -0,0 +1,177 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+)
+
+// maxRequestIDLength caps the request IDs accepted from clients, longer ones are replaced by a new ID.
+const maxRequestIDLength = 128
+
+type loggerContextKey struct{}
+
+type requestLogContextKey struct{}
+
+// WithLogger returns a context carrying the logger of a request.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// LoggerFromContext returns the logger of the request set up by [LogRequests], or the default logger outside of a
+// request.
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerContextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// requestLog is filled in by the routes, for the access log line of [LogRequests].
+type requestLog struct {
+	route     string
+	dsn       string
+	principal string
+}
+
+// LogRequests assigns every request an ID, or keeps the one sent in the [ducktape.RequestIDHeader] header, and echoes
+// it back. The handlers get a logger carrying the ID through [LoggerFromContext], and one access log line is written
+// per request once it has been served.
+func LogRequests(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := requestID(r)
+		w.Header().Set(ducktape.RequestIDHeader, id)
+
+		logger := slog.Default().With(slog.String("requestId", id))
+		entry := &requestLog{}
+		ctx := context.WithValue(WithLogger(r.Context(), logger), requestLogContextKey{}, entry)
+		body := &countingReader{ReadCloser: r.Body}
+		r = r.WithContext(ctx)
+		r.Body = body
+		recorder := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+		handler.ServeHTTP(recorder, r)
+
+		// Requests that are rejected before reaching a route, e.g. with 401 Unauthorized, only have the pattern of
+		// the outer mux
+		route := entry.route
+		if route == "" {
+			route = r.Pattern
+		}
+		logger.LogAttrs(ctx, slog.LevelInfo, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", route),
+			slog.Int("status", recorder.status),
+			slog.Duration("duration", time.Since(start)),
+			slog.Int64("requestBytes", body.n),
+			slog.Int64("responseBytes", recorder.n),
+			slog.String("dsn", entry.dsn),
+			slog.String("principal", entry.principal),
+			slog.String("clientAddress", r.RemoteAddr),
+			slog.String("protocol", r.Proto),
+			slog.String("userAgent", r.UserAgent()),
+		)
+	})
+}
+
+// requestID returns the request ID sent by the client, or a new one when it is missing or not printable ASCII.
+func requestID(r *http.Request) string {
+	id := r.Header.Get(ducktape.RequestIDHeader)
+	if id == "" || len(id) > maxRequestIDLength || strings.ContainsFunc(id, func(c rune) bool { return c < '!' || c > '~' }) {
+		return rand.Text()
+	}
+	return id
+}
+
+// withRequestLogger adds the route, the DSN, the principal and the trace of a request to its logger and to its access log line.
+// The logger also travels with the response writer, for the helpers that only get the writer.
+func withRequestLogger(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := PrincipalFromContext(r.Context())
+		dsn := dsnPath(r.Header.Get(ducktape.DuckDBConnectionStringHeader))
+		if entry, ok := r.Context().Value(requestLogContextKey{}).(*requestLog); ok {
+			entry.route = r.Pattern
+			entry.dsn = dsn
+			entry.principal = principal.Name
+		}
+
+		logger := LoggerFromContext(r.Context()).With(
+			slog.String("route", r.Pattern),
+			slog.String("dsn", dsn),
+			slog.String("principal", principal.Name),
+		)
+		if span := trace.SpanContextFromContext(r.Context()); span.IsValid() {
+			logger = logger.With(slog.String("traceId", span.TraceID().String()), slog.String("spanId", span.SpanID().String()))
+		}
+		handler(&loggerWriter{ResponseWriter: w, logger: logger}, r.WithContext(WithLogger(r.Context(), logger)))
+	}
+}
+
+// responseLogger returns the logger of the request that w answers, see [withRequestLogger].
+func responseLogger(w http.ResponseWriter) *slog.Logger {
+	if lw, ok := w.(*loggerWriter); ok {
+		return lw.logger
+	}
+	return slog.Default()
+}
+
+type loggerWriter struct {
+	http.ResponseWriter
+	logger *slog.Logger
+}
+
+func (w *loggerWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// recordingWriter records the status and the size of a response, for the access log line and the span of a request.
+type recordingWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	n           int64
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+func (w *recordingWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *recordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// countingReader counts the bytes read from a request body.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	stdjson "encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+)
+
+func TestLogRequests(t *testing.T) {
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	apiMux := http.NewServeMux()
+	RegisterApiRoutes(apiMux)
+	mux := http.NewServeMux()
+	mux.Handle("/api/", Authenticate(apiMux))
+	handler := LogRequests(mux)
+
+	serve := func(requestID string, body string) *httptest.ResponseRecorder {
+		logs.Reset()
+		r := httptest.NewRequest("POST", ducktape.QueryRoute, strings.NewReader(body))
+		r.Header.Set(ducktape.DuckDBConnectionStringHeader, ":memory:?threads=1")
+		r.Header.Set(ducktape.RequestIDHeader, requestID)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	entries := func() map[string]map[string]any {
+		result := make(map[string]map[string]any)
+		scanner := bufio.NewScanner(&logs)
+		for scanner.Scan() {
+			var entry map[string]any
+			if err := stdjson.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				t.Fatalf("failed to parse log line %q: %v", scanner.Text(), err)
+			}
+			result[entry["msg"].(string)] = entry
+		}
+		return result
+	}
+
+	body := `{"query":"SELECT 42 AS answer"}`
+	w := serve("client-id-1", body)
+	if w.Code != http.StatusOK || w.Header().Get(ducktape.RequestIDHeader) != "client-id-1" {
+		t.Fatalf("expected the request ID to be echoed, got %d with %q", w.Code, w.Header().Get(ducktape.RequestIDHeader))
+	}
+	logged := entries()
+	handlerLine, ok := logged["querying duckdb"]
+	if !ok || handlerLine["requestId"] != "client-id-1" || handlerLine["route"] != "POST /api/query" || handlerLine["dsn"] != ":memory:" {
+		t.Errorf("expected the handler logs to carry the request, got %v", handlerLine)
+	}
+	access, ok := logged["request"]
+	if !ok {
+		t.Fatalf("expected an access log line, got %v", logged)
+	}
+	if access["requestId"] != "client-id-1" || access["status"] != float64(http.StatusOK) || access["route"] != "POST /api/query" ||
+		access["requestBytes"] != float64(len(body)) || access["responseBytes"] != float64(w.Body.Len()) {
+		t.Errorf("unexpected access log line: %v", access)
+	}
+
+	w = serve("not a valid id", `{"query":`)
+	generated := w.Header().Get(ducktape.RequestIDHeader)
+	if generated == "" || generated == "not a valid id" {
+		t.Errorf("expected a new request ID, got %q", generated)
+	}
+	logged = entries()
+	if line := logged["returning bad request"]; line["requestId"] != generated {
+		t.Errorf("expected the error logs to carry the request ID, got %v", line)
+	}
+	if access := logged["request"]; access["status"] != float64(http.StatusBadRequest) {
+		t.Errorf("expected the status to be logged, got %v", access)
+	}
+}
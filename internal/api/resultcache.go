@@ -0,0 +1,141 @@
+package api
+
+import (
+	"container/list"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+)
+
+var results = newResultCache()
+
+type cachedResult struct {
+	key       string
+	database  string
+	version   uint64
+	body      []byte
+	expiresAt time.Time
+	element   *list.Element
+}
+
+// resultCache keeps query responses for the TTL requested by the client, within the [Options.ResultCacheBytes]
+// budget. Every database has a version that is bumped by the writes made through this server (execute, append and
+// transaction commits), and responses cached under an older version are discarded. Writes made by other processes are
+// only picked up once the TTL expires.
+type resultCache struct {
+	mu       sync.Mutex
+	lru      *list.List
+	entries  map[string]*cachedResult
+	versions map[string]uint64
+	size     int64
+}
+
+func newResultCache() *resultCache {
+	return &resultCache{
+		lru:      list.New(),
+		entries:  make(map[string]*cachedResult),
+		versions: make(map[string]uint64),
+	}
+}
+
+// resultKey identifies a query response, args are part of the key through their JSON encoding.
+func resultKey(dsn string, request ducktape.QueryRequest) (string, error) {
+	args, err := json.Marshal([]any{request.Args, request.NamedArgs})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal the query args: %w", err)
+	}
+	return strings.Join([]string{dsn, request.Query, string(args)}, "\x00"), nil
+}
+
+func (c *resultCache) enabled(dsn string) bool {
+	return options.ResultCacheBytes > 0 && !isInMemoryDSN(dsn)
+}
+
+// version returns the current version of the database, to be passed to [resultCache.put] once the query has run.
+func (c *resultCache) version(dsn string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.versions[databaseKey(dsn)]
+}
+
+func (c *resultCache) get(dsn string, key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		resultCacheLookups.WithLabelValues("miss").Inc()
+		return nil, false
+	}
+	if time.Now().After(entry.expiresAt) || entry.version != c.versions[entry.database] {
+		c.remove(entry)
+		resultCacheLookups.WithLabelValues("miss").Inc()
+		return nil, false
+	}
+	c.lru.MoveToFront(entry.element)
+	resultCacheLookups.WithLabelValues("hit").Inc()
+	return entry.body, true
+}
+
+// put caches a response that was computed at the given database version, it is dropped if a write happened meanwhile
+// or if it does not fit in the budget on its own.
+func (c *resultCache) put(dsn string, key string, version uint64, body []byte, ttl time.Duration) {
+	database := databaseKey(dsn)
+	size := int64(len(body) + len(key))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if version != c.versions[database] || size > options.ResultCacheBytes {
+		return
+	}
+	if existing, ok := c.entries[key]; ok {
+		c.remove(existing)
+	}
+
+	entry := &cachedResult{key: key, database: database, version: version, body: body, expiresAt: time.Now().Add(ttl)}
+	entry.element = c.lru.PushFront(entry)
+	c.entries[key] = entry
+	c.size += size
+	for c.size > options.ResultCacheBytes {
+		c.remove(c.lru.Back().Value.(*cachedResult))
+	}
+}
+
+// remove must be called with c.mu held.
+func (c *resultCache) remove(entry *cachedResult) {
+	c.lru.Remove(entry.element)
+	delete(c.entries, entry.key)
+	c.size -= int64(len(entry.body) + len(entry.key))
+}
+
+// invalidate bumps the version of the database, discarding every response cached for it.
+func (c *resultCache) invalidate(dsn string) {
+	if dsn == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.versions[databaseKey(dsn)]++
+}
+
+// resultCacheTTL returns how long the client asked to cache the query response for, zero means not cached.
+func resultCacheTTL(r *http.Request) (time.Duration, error) {
+	header := r.Header.Get(ducktape.DuckDBCacheTTLHeader)
+	if header == "" {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(header)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse the %q header: %w", ducktape.DuckDBCacheTTLHeader, err)
+	}
+	if ttl <= 0 {
+		return 0, fmt.Errorf("%q header must be positive, got %q", ducktape.DuckDBCacheTTLHeader, header)
+	}
+	return ttl, nil
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+	_ "github.com/duckdb/duckdb-go/v2"
+)
+
+func TestResultCache(t *testing.T) {
+	ctx := context.Background()
+	dsn := "test_result_cache.db"
+	results = newResultCache()
+	Configure(Options{ResultCacheBytes: 1 << 20})
+	t.Cleanup(func() {
+		Configure(Options{})
+		results = newResultCache()
+		os.Remove(dsn)
+	})
+
+	_, err := Execute(ctx, dsn, ducktape.ExecuteRequest{
+		Statements: []ducktape.ExecuteStatement{
+			{Query: `CREATE TABLE test_result_cache AS SELECT range AS id FROM range(3)`},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	query := func(t *testing.T, body string, ttl string) (string, string) {
+		t.Helper()
+		r := httptest.NewRequest("POST", ducktape.QueryRoute, strings.NewReader(body))
+		r.Header.Set(ducktape.DuckDBConnectionStringHeader, dsn)
+		if ttl != "" {
+			r.Header.Set(ducktape.DuckDBCacheTTLHeader, ttl)
+		}
+		w := httptest.NewRecorder()
+		handleQuery(w, r)
+		if w.Code != 200 {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		return w.Header().Get(ducktape.DuckDBCacheHeader), w.Body.String()
+	}
+	insert := func(t *testing.T) {
+		t.Helper()
+		_, err := Execute(ctx, dsn, ducktape.ExecuteRequest{
+			Statements: []ducktape.ExecuteStatement{{Query: `INSERT INTO test_result_cache VALUES (100)`}},
+		})
+		if err != nil {
+			t.Fatalf("failed to insert: %v", err)
+		}
+	}
+	count := `{"query": "SELECT count(*) AS count FROM test_result_cache"}`
+
+	t.Run("second query is a hit", func(t *testing.T) {
+		status, first := query(t, count, "1m")
+		if status != "miss" {
+			t.Errorf("expected a miss, got %q", status)
+		}
+		status, second := query(t, count, "1m")
+		if status != "hit" || second != first {
+			t.Errorf("expected a hit with %q, got %q with %q", first, status, second)
+		}
+	})
+
+	t.Run("writes invalidate the database", func(t *testing.T) {
+		_, before := query(t, count, "1m")
+		insert(t)
+		status, after := query(t, count, "1m")
+		if status != "miss" || after == before {
+			t.Errorf("expected a fresh result after the insert, got %q with %q", status, after)
+		}
+	})
+
+	t.Run("writes through the query route invalidate the database", func(t *testing.T) {
+		_, before := query(t, count, "1m")
+		query(t, `{"query": "INSERT INTO test_result_cache VALUES (101) RETURNING id"}`, "")
+		status, after := query(t, count, "1m")
+		if status != "miss" || after == before {
+			t.Errorf("expected a fresh result after the insert, got %q with %q", status, after)
+		}
+	})
+
+	t.Run("args are part of the key", func(t *testing.T) {
+		body := `{"query": "SELECT count(*) AS count FROM test_result_cache WHERE id >= ?", "args": [%d]}`
+		query(t, fmt.Sprintf(body, 1), "1m")
+		if status, _ := query(t, fmt.Sprintf(body, 2), "1m"); status != "miss" {
+			t.Errorf("expected a miss for different args, got %q", status)
+		}
+		if status, _ := query(t, fmt.Sprintf(body, 1), "1m"); status != "hit" {
+			t.Errorf("expected a hit for the same args, got %q", status)
+		}
+	})
+
+	t.Run("entries expire", func(t *testing.T) {
+		body := `{"query": "SELECT max(id) AS id FROM test_result_cache"}`
+		query(t, body, "20ms")
+		time.Sleep(50 * time.Millisecond)
+		if status, _ := query(t, body, "20ms"); status != "miss" {
+			t.Errorf("expected a miss after the TTL, got %q", status)
+		}
+	})
+
+	t.Run("not cached without the header", func(t *testing.T) {
+		body := `{"query": "SELECT min(id) AS id FROM test_result_cache"}`
+		query(t, body, "")
+		if status, _ := query(t, body, ""); status != "" {
+			t.Errorf("expected no cache status, got %q", status)
+		}
+	})
+
+	t.Run("only selects are cached", func(t *testing.T) {
+		body := `{"query": "EXPLAIN SELECT id FROM test_result_cache"}`
+		query(t, body, "1m")
+		if status, _ := query(t, body, "1m"); status != "miss" {
+			t.Errorf("expected a miss for a non-SELECT statement, got %q", status)
+		}
+
+		body = `{"query": "SELECT 1 AS id; SELECT 2 AS id"}`
+		query(t, body, "1m")
+		if status, _ := query(t, body, "1m"); status != "miss" {
+			t.Errorf("expected a miss for multiple statements, got %q", status)
+		}
+	})
+
+	t.Run("invalid ttl", func(t *testing.T) {
+		r := httptest.NewRequest("POST", ducktape.QueryRoute, strings.NewReader(count))
+		r.Header.Set(ducktape.DuckDBConnectionStringHeader, dsn)
+		r.Header.Set(ducktape.DuckDBCacheTTLHeader, "-1s")
+		w := httptest.NewRecorder()
+		handleQuery(w, r)
+		if w.Code != 400 {
+			t.Errorf("expected status 400, got %d", w.Code)
+		}
+	})
+}
+
+func TestResultCacheBudget(t *testing.T) {
+	Configure(Options{ResultCacheBytes: 100})
+	t.Cleanup(func() { Configure(Options{}) })
+
+	cache := newResultCache()
+	dsn := "test_result_cache_budget.db"
+	body := []byte(strings.Repeat("x", 40))
+	for _, key := range []string{"a", "b", "c"} {
+		cache.put(dsn, key, cache.version(dsn), body, time.Minute)
+	}
+	if _, ok := cache.get(dsn, "a"); ok {
+		t.Error("expected the least recently used entry to be evicted")
+	}
+	for _, key := range []string{"b", "c"} {
+		if _, ok := cache.get(dsn, key); !ok {
+			t.Errorf("expected %q to be cached", key)
+		}
+	}
+
+	cache.put(dsn, "large", cache.version(dsn), []byte(strings.Repeat("x", 200)), time.Minute)
+	if _, ok := cache.get(dsn, "large"); ok {
+		t.Error("expected an entry larger than the budget not to be cached")
+	}
+
+	version := cache.version(dsn)
+	cache.invalidate(dsn + "?threads=1")
+	cache.put(dsn, "stale", version, body, time.Minute)
+	if _, ok := cache.get(dsn, "stale"); ok {
+		t.Error("expected a result computed before a write not to be cached")
+	}
+	if _, ok := cache.get(dsn, "b"); ok {
+		t.Error("expected the write to invalidate the database")
+	}
+}
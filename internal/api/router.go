@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -13,18 +14,44 @@ import (
 
 var json = jsoniter.ConfigCompatibleWithStandardLibrary
 
-func RegisterHealthCheckRoutes(mux *http.ServeMux) {
+// requestJSON decodes request bodies, keeping numbers as [stdjson.Number] so integer args are bound exactly.
+var requestJSON = jsoniter.Config{
+	EscapeHTML:             true,
+	SortMapKeys:            true,
+	ValidateJsonRawMessage: true,
+	UseNumber:              true,
+}.Froze()
+
+// RegisterHealthCheckRoutes serves the health checks without authentication. The readiness checks are detailed for the
+// callers that one of the authenticators accepts, see [Authenticate].
+func RegisterHealthCheckRoutes(mux *http.ServeMux, authenticators ...Authenticator) {
 	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	})
+	mux.HandleFunc(fmt.Sprintf("GET %s", ducktape.LivenessRoute), handleLiveness)
+	mux.HandleFunc(fmt.Sprintf("GET %s", ducktape.ReadinessRoute), handleReadiness(authenticators))
 }
 
 func RegisterApiRoutes(mux *http.ServeMux) {
-	mux.HandleFunc(fmt.Sprintf("POST %s", ducktape.ExecuteRoute), handleExecute)
-	mux.HandleFunc(fmt.Sprintf("POST %s", ducktape.QueryRoute), handleQuery)
-	mux.HandleFunc(fmt.Sprintf("POST %s", ducktape.AppendRoute), handleAppend)
-	mux.HandleFunc(fmt.Sprintf("GET %s", ducktape.PingRoute), handlePing)
+	handle := func(pattern string, operation Operation, handler http.HandlerFunc) {
+		mux.HandleFunc(pattern, instrumentRoute(pattern, traceRoute(withRequestLogger(limitRequestBody(authorized(operation, handler))))))
+	}
+	handle(fmt.Sprintf("POST %s", ducktape.ExecuteRoute), OperationExecute, handleExecute)
+	handle(fmt.Sprintf("POST %s", ducktape.QueryRoute), OperationQuery, handleQuery)
+	handle(fmt.Sprintf("POST %s", ducktape.AppendRoute), OperationAppend, handleAppend)
+	handle(fmt.Sprintf("GET %s", ducktape.PingRoute), OperationPing, handlePing)
+	handle(fmt.Sprintf("POST %s", ducktape.ExplainRoute), OperationQuery, handleExplain)
+	handle(fmt.Sprintf("POST %s", ducktape.BeginTransactionRoute), OperationQuery, handleBeginTransaction)
+	handle(fmt.Sprintf("POST %s", ducktape.CommitTransactionRoute), OperationExecute, handleCommitTransaction)
+	handle(fmt.Sprintf("POST %s", ducktape.RollbackTransactionRoute), OperationExecute, handleRollbackTransaction)
+	handle(fmt.Sprintf("GET %s", ducktape.OperationsRoute), OperationAdmin, handleListOperations)
+	handle(fmt.Sprintf("POST %s", ducktape.CancelOperationRoute), OperationAdmin, handleCancelOperation)
+	handle(fmt.Sprintf("GET %s", ducktape.StatementCacheRoute), OperationAdmin, handleStatementCacheStats)
+	handle(fmt.Sprintf("POST %s", ducktape.JobsRoute), OperationQuery, handleSubmitJob)
+	handle(fmt.Sprintf("GET %s", ducktape.JobRoute), OperationQuery, handleGetJob)
+	handle(fmt.Sprintf("GET %s", ducktape.JobResultsRoute), OperationQuery, handleJobResults)
+	handle(fmt.Sprintf("POST %s", ducktape.CancelJobRoute), OperationQuery, handleCancelJob)
 }
 
 func getRequestBody[T any](r *http.Request) (T, error) {
@@ -32,32 +59,108 @@ func getRequestBody[T any](r *http.Request) (T, error) {
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		var zero T
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return zero, fmt.Errorf("%w: the limit is %d bytes", ErrRequestTooLarge, maxBytesErr.Limit)
+		}
 		return zero, fmt.Errorf("failed to read the request body: %v", err)
 	}
-	if err := json.Unmarshal(body, &request); err != nil {
+	if err := requestJSON.Unmarshal(body, &request); err != nil {
 		var zero T
 		return zero, fmt.Errorf("failed to unmarshal the request: %v", err)
 	}
 	return request, nil
 }
 
-func handleBadRequestJSON[T any](w http.ResponseWriter, response T, err error) {
-	slog.Error("returning bad request", slog.Any("error", err))
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusBadRequest)
-
-	body, marshalErr := json.Marshal(response)
-	if marshalErr != nil {
-		w.Write([]byte(err.Error()))
+// handleRequestBodyErrorJSON rejects a request whose body could not be read by [getRequestBody].
+func handleRequestBodyErrorJSON[T any](w http.ResponseWriter, response T, err error) {
+	if errors.Is(err, ErrRequestTooLarge) {
+		handleRequestTooLargeJSON(w, response, err)
 		return
 	}
-	w.Write(body)
+	handleBadRequestJSON(w, response, err)
+}
+
+// ErrInvalidRequest is wrapped by the errors of requests that were decoded but cannot be run as sent, e.g. with an
+// unknown option. They are returned with 400 Bad Request.
+var ErrInvalidRequest = errors.New("invalid request")
+
+// handleErrorJSON picks the response status from the error, falling back to an internal server error.
+func handleErrorJSON[T any](w http.ResponseWriter, response T, err error) {
+	switch {
+	case errors.Is(err, ErrTransactionNotFound), errors.Is(err, ErrOperationNotFound), errors.Is(err, ErrCursorNotFound),
+		errors.Is(err, ErrJobNotFound):
+		handleNotFoundJSON(w, response, err)
+	case errors.Is(err, ErrJobNotFinished), errors.Is(err, ErrJobFailed), errors.Is(err, ErrIdempotencyKeyConflict):
+		handleConflictJSON(w, response, err)
+	case errors.Is(err, ErrTransactionDSNMismatch), errors.Is(err, ErrCursorDSNMismatch), errors.Is(err, ErrInvalidRequest):
+		handleBadRequestJSON(w, response, err)
+	case errors.Is(err, ErrReadOnly), errors.Is(err, ErrDSNNotAllowed), errors.Is(err, ErrForbidden):
+		handleForbiddenJSON(w, response, err)
+	case errors.Is(err, ErrIdempotencyKeyReused):
+		handleUnprocessableEntityJSON(w, response, err)
+	case errors.Is(err, ErrQueryTimeout):
+		handleTimeoutJSON(w, response, err)
+	case errors.Is(err, ErrShuttingDown):
+		handleServiceUnavailableJSON(w, response, err)
+	case errors.Is(err, ErrOperationCancelled):
+		// Checked after ErrShuttingDown, which the operations cancelled on shutdown wrap as well
+		handleConflictJSON(w, response, err)
+	case errors.Is(err, ErrRequestTooLarge), errors.As(err, new(*http.MaxBytesError)):
+		handleRequestTooLargeJSON(w, response, err)
+	default:
+		handleInternalServerErrorJSON(w, response, err)
+	}
+}
+
+func handleBadRequestJSON[T any](w http.ResponseWriter, response T, err error) {
+	responseLogger(w).Error("returning bad request", slog.Any("error", err))
+	writeErrorJSON(w, http.StatusBadRequest, response, err)
+}
+
+func handleForbiddenJSON[T any](w http.ResponseWriter, response T, err error) {
+	responseLogger(w).Error("returning forbidden", slog.Any("error", err))
+	writeErrorJSON(w, http.StatusForbidden, response, err)
+}
+
+func handleNotFoundJSON[T any](w http.ResponseWriter, response T, err error) {
+	responseLogger(w).Error("returning not found", slog.Any("error", err))
+	writeErrorJSON(w, http.StatusNotFound, response, err)
+}
+
+func handleConflictJSON[T any](w http.ResponseWriter, response T, err error) {
+	responseLogger(w).Error("returning conflict", slog.Any("error", err))
+	writeErrorJSON(w, http.StatusConflict, response, err)
+}
+
+func handleUnprocessableEntityJSON[T any](w http.ResponseWriter, response T, err error) {
+	responseLogger(w).Error("returning unprocessable entity", slog.Any("error", err))
+	writeErrorJSON(w, http.StatusUnprocessableEntity, response, err)
+}
+
+func handleTimeoutJSON[T any](w http.ResponseWriter, response T, err error) {
+	responseLogger(w).Error("returning gateway timeout", slog.Any("error", err))
+	writeErrorJSON(w, http.StatusGatewayTimeout, response, err)
+}
+
+func handleRequestTooLargeJSON[T any](w http.ResponseWriter, response T, err error) {
+	responseLogger(w).Error("returning request entity too large", slog.Any("error", err))
+	writeErrorJSON(w, http.StatusRequestEntityTooLarge, response, err)
+}
+
+func handleServiceUnavailableJSON[T any](w http.ResponseWriter, response T, err error) {
+	responseLogger(w).Error("returning service unavailable", slog.Any("error", err))
+	writeErrorJSON(w, http.StatusServiceUnavailable, response, err)
 }
 
 func handleInternalServerErrorJSON[T any](w http.ResponseWriter, response T, err error) {
-	slog.Error("returning internal server error", slog.Any("error", err))
+	responseLogger(w).Error("returning internal server error", slog.Any("error", err))
+	writeErrorJSON(w, http.StatusInternalServerError, response, err)
+}
+
+func writeErrorJSON[T any](w http.ResponseWriter, statusCode int, response T, err error) {
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusInternalServerError)
+	w.WriteHeader(statusCode)
 
 	body, marshalErr := json.Marshal(response)
 	if marshalErr != nil {
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// ErrShuttingDown is returned for the work that the server refuses or cancels because it is shutting down.
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// shutdownCancelGrace is how long [Shutdown] waits for cancelled operations to return before closing the databases
+// anyway.
+const shutdownCancelGrace = 10 * time.Second
+
+var shuttingDown atomic.Bool
+
+// Shutdown stops the API once the HTTP server has stopped accepting requests, see [http.Server.Shutdown]. It waits
+// for the in-flight operations and jobs to finish until the context is done, then cancels the remaining ones, which
+// flushes the rows their appenders buffered. Open transactions are then rolled back, cursors and cached statements are
+// closed, and the databases they held are checkpointed.
+func Shutdown(ctx context.Context) error {
+	Drain()
+	shuttingDown.Store(true)
+
+	var err error
+	if waitErr := operations.wait(ctx); waitErr != nil {
+		cancelled := operations.cancelAll(fmt.Errorf("%w: %w", ErrOperationCancelled, ErrShuttingDown))
+		slog.Warn("cancelling the operations that did not finish in time", slog.Int("operations", cancelled))
+
+		graceCtx, cancel := context.WithTimeout(context.Background(), shutdownCancelGrace)
+		defer cancel()
+		if waitErr := operations.wait(graceCtx); waitErr != nil {
+			err = fmt.Errorf("operations did not return after being cancelled: %w", waitErr)
+		}
+	}
+
+	transactions.close()
+	cursors.close()
+	statements.close()
+	return err
+}
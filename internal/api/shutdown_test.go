@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+	_ "github.com/duckdb/duckdb-go/v2"
+)
+
+func TestShutdown(t *testing.T) {
+	ctx := context.Background()
+	dsn := "test_shutdown.db"
+	Configure(Options{JobDir: t.TempDir()})
+	t.Cleanup(func() {
+		shuttingDown.Store(false)
+		draining.Store(false)
+		transactions = newTransactionStore(defaultTransactionIdleTimeout)
+		Configure(Options{})
+		os.Remove(dsn)
+		os.Remove(dsn + ".wal")
+	})
+
+	_, err := Execute(ctx, dsn, ducktape.ExecuteRequest{
+		Statements: []ducktape.ExecuteStatement{{Query: `CREATE TABLE test_shutdown AS SELECT 1 AS id`}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	transactionID, err := BeginTransaction(dsn)
+	if err != nil {
+		t.Fatalf("failed to begin transaction: %v", err)
+	}
+	if _, err := ExecuteInTransaction(ctx, transactionID, dsn, ducktape.ExecuteRequest{
+		Statements: []ducktape.ExecuteStatement{{Query: `INSERT INTO test_shutdown VALUES (2)`}},
+	}); err != nil {
+		t.Fatalf("failed to execute in transaction: %v", err)
+	}
+
+	finished, err := SubmitJob(dsn, ducktape.QueryRequest{Query: "SELECT * FROM test_shutdown"}, 0)
+	if err != nil {
+		t.Fatalf("failed to submit job: %v", err)
+	}
+	waitForJob(t, finished.ID)
+	running, err := SubmitJob(dsn, ducktape.QueryRequest{Query: "SELECT count(*) FROM range(1000000000000)"}, 0)
+	if err != nil {
+		t.Fatalf("failed to submit job: %v", err)
+	}
+
+	drainCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if err := Shutdown(drainCtx); err != nil {
+		t.Fatalf("failed to shut down: %v", err)
+	}
+
+	if job, _ := GetJob(running.ID); job.State != ducktape.JobStateCancelled {
+		t.Errorf("expected the running job to be cancelled, got %q", job.State)
+	}
+	if len(ListOperations()) != 0 {
+		t.Errorf("expected no operation to be left, got %v", ListOperations())
+	}
+	if _, err := SubmitJob(dsn, ducktape.QueryRequest{Query: "SELECT 1"}, 0); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("expected jobs to be refused, got %v", err)
+	}
+	if _, err := BeginTransaction(dsn); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("expected transactions to be refused, got %v", err)
+	}
+	if err := CommitTransaction(transactionID, dsn); !errors.Is(err, ErrTransactionNotFound) {
+		t.Errorf("expected the open transaction to be rolled back, got %v", err)
+	}
+
+	rows, err := Query(ctx, dsn, ducktape.QueryRequest{Query: "SELECT * FROM test_shutdown"})
+	if err != nil || len(rows) != 1 {
+		t.Errorf("expected the rolled back insert to be discarded, got %v (%v)", rows, err)
+	}
+}
@@ -0,0 +1,278 @@
+package api
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+	"github.com/duckdb/duckdb-go/v2"
+)
+
+// errStatementNotCacheable is returned for statements that must go through the regular, uncached query path.
+var errStatementNotCacheable = errors.New("statement cannot be cached")
+
+var statements = newStatementCache()
+
+type statementKey struct {
+	dsn   string
+	query string
+}
+
+type cachedStatement struct {
+	key     statementKey
+	stmt    *sql.Stmt
+	element *list.Element
+
+	// Guarded by statementCache.mu
+	inUse   int
+	evicted bool
+}
+
+// statementDB is a DuckDB handle shared by the cached statements of a DSN, it is closed with the last of them.
+type statementDB struct {
+	db         *sql.DB
+	statements int
+}
+
+// statementCache keeps up to [Options.StatementCacheSize] prepared statements, keyed by DSN and SQL text, and evicts
+// the least recently used ones. Cached statements hold their database open, so in-memory databases are never cached:
+// each request would otherwise share the same in-memory database instead of getting a fresh one.
+type statementCache struct {
+	mu         sync.Mutex
+	lru        *list.List
+	statements map[statementKey]*cachedStatement
+	dbs        map[string]*statementDB
+
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+func newStatementCache() *statementCache {
+	return &statementCache{
+		lru:        list.New(),
+		statements: make(map[statementKey]*cachedStatement),
+		dbs:        make(map[string]*statementDB),
+	}
+}
+
+func (s *statementCache) enabled(dsn string) bool {
+	return options.StatementCacheSize > 0 && !isInMemoryDSN(dsn)
+}
+
+// acquire returns the prepared statement for the query, preparing it on a miss. Callers must call release once the
+// statement has been executed, results that are still open keep the statement alive on their own.
+func (s *statementCache) acquire(ctx context.Context, dsn string, query string) (*cachedStatement, error) {
+	key := statementKey{dsn: dsn, query: query}
+
+	s.mu.Lock()
+	if cached, ok := s.statements[key]; ok {
+		cached.inUse++
+		s.lru.MoveToFront(cached.element)
+		s.hits++
+		s.mu.Unlock()
+		return cached, nil
+	}
+	s.misses++
+	s.mu.Unlock()
+
+	db, err := s.acquireDB(ctx, dsn)
+	if err != nil {
+		return nil, err
+	}
+	stmt, err := prepareStatement(ctx, db, query)
+	if err != nil {
+		s.releaseDB(dsn)
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if cached, ok := s.statements[key]; ok {
+		// Another request prepared the same statement in the meantime
+		cached.inUse++
+		s.lru.MoveToFront(cached.element)
+		s.mu.Unlock()
+		stmt.Close()
+		s.releaseDB(dsn)
+		return cached, nil
+	}
+
+	cached := &cachedStatement{key: key, stmt: stmt, inUse: 1}
+	cached.element = s.lru.PushFront(cached)
+	s.statements[key] = cached
+
+	var evicted []*cachedStatement
+	for s.lru.Len() > max(options.StatementCacheSize, 1) {
+		oldest := s.lru.Back().Value.(*cachedStatement)
+		s.lru.Remove(oldest.element)
+		delete(s.statements, oldest.key)
+		oldest.evicted = true
+		s.evictions++
+		if oldest.inUse == 0 {
+			evicted = append(evicted, oldest)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, statement := range evicted {
+		s.closeStatement(statement)
+	}
+	return cached, nil
+}
+
+func (s *statementCache) release(cached *cachedStatement) {
+	s.mu.Lock()
+	cached.inUse--
+	closeNow := cached.evicted && cached.inUse == 0
+	s.mu.Unlock()
+
+	if closeNow {
+		s.closeStatement(cached)
+	}
+}
+
+func (s *statementCache) closeStatement(cached *cachedStatement) {
+	if err := cached.stmt.Close(); err != nil {
+		slog.Error("failed to close cached statement", slog.Any("error", err))
+	}
+	s.releaseDB(cached.key.dsn)
+}
+
+func (s *statementCache) acquireDB(ctx context.Context, dsn string) (*sql.DB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if shared, ok := s.dbs[dsn]; ok {
+		shared.statements++
+		return shared.db, nil
+	}
+	db, err := openDB(ctx, dsn, "statement cache")
+	if err != nil {
+		return nil, err
+	}
+	s.dbs[dsn] = &statementDB{db: db, statements: 1}
+	return db, nil
+}
+
+func (s *statementCache) releaseDB(dsn string) {
+	s.mu.Lock()
+	shared := s.dbs[dsn]
+	shared.statements--
+	if shared.statements > 0 {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.dbs, dsn)
+	s.mu.Unlock()
+
+	if err := shared.db.Close(); err != nil {
+		slog.Error("failed to close the statement cache database", slog.Any("error", err))
+	}
+}
+
+// close evicts every statement, closing the ones that are not in use right away and the others once released. The
+// databases are checkpointed first, they are closed with their last statement.
+func (s *statementCache) close() {
+	s.mu.Lock()
+	for dsn, shared := range s.dbs {
+		checkpointDB(shared.db, dsn)
+	}
+	var evicted []*cachedStatement
+	for element := s.lru.Front(); element != nil; element = element.Next() {
+		cached := element.Value.(*cachedStatement)
+		cached.evicted = true
+		if cached.inUse == 0 {
+			evicted = append(evicted, cached)
+		}
+	}
+	s.lru.Init()
+	clear(s.statements)
+	s.mu.Unlock()
+
+	for _, cached := range evicted {
+		s.closeStatement(cached)
+	}
+}
+
+func (s *statementCache) stats() ducktape.StatementCacheStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := ducktape.StatementCacheStats{
+		Capacity:  options.StatementCacheSize,
+		Size:      s.lru.Len(),
+		Hits:      s.hits,
+		Misses:    s.misses,
+		Evictions: s.evictions,
+	}
+	if lookups := s.hits + s.misses; lookups > 0 {
+		stats.HitRate = float64(s.hits) / float64(lookups)
+	}
+	return stats
+}
+
+// prepareStatement prepares a single statement. database/sql would run every statement but the last one of a
+// multi-statement query at prepare time, so those are reported as not cacheable, as are statements that fail to
+// prepare, leaving the regular query path to report the error.
+func prepareStatement(ctx context.Context, db *sql.DB, query string) (*sql.Stmt, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get a connection for statement cache(%q): %w", "duckdb", err)
+	}
+	defer conn.Close()
+
+	err = conn.Raw(func(driverConn any) error {
+		// Prepare (unlike PrepareContext) refuses multiple statements instead of executing all but the last one.
+		driverStmt, err := driverConn.(*duckdb.Conn).Prepare(query)
+		if err != nil {
+			return err
+		}
+		return driverStmt.Close()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errStatementNotCacheable, err)
+	}
+
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errStatementNotCacheable, err)
+	}
+	return stmt, nil
+}
+
+// cachedQueryer runs queries through the statement cache of a DSN.
+type cachedQueryer struct {
+	dsn string
+}
+
+func (q cachedQueryer) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	cached, err := statements.acquire(ctx, q.dsn, query)
+	if err != nil {
+		return nil, err
+	}
+	defer statements.release(cached)
+	return cached.stmt.QueryContext(ctx, args...)
+}
+
+func GetStatementCacheStats() ducktape.StatementCacheStats {
+	return statements.stats()
+}
+
+func handleStatementCacheStats(w http.ResponseWriter, r *http.Request) {
+	body, err := json.Marshal(ducktape.StatementCacheStatsResponse{Stats: GetStatementCacheStats()})
+	if err != nil {
+		err := fmt.Errorf("failed to marshal the response: %v", err)
+		errMsg := err.Error()
+		handleInternalServerErrorJSON(w, ducktape.StatementCacheStatsResponse{Error: &errMsg}, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+	_ "github.com/duckdb/duckdb-go/v2"
+)
+
+func TestStatementCache(t *testing.T) {
+	ctx := context.Background()
+	dsn := "test_statement_cache.db"
+	statements = newStatementCache()
+	Configure(Options{StatementCacheSize: 2})
+	t.Cleanup(func() {
+		statements.close()
+		statements = newStatementCache()
+		Configure(Options{})
+		os.Remove(dsn)
+	})
+
+	_, err := Execute(ctx, dsn, ducktape.ExecuteRequest{
+		Statements: []ducktape.ExecuteStatement{
+			{Query: `CREATE TABLE test_statement_cache AS SELECT range AS id FROM range(10)`},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	t.Run("repeated queries hit the cache", func(t *testing.T) {
+		for i := range 3 {
+			rows, err := Query(ctx, dsn, ducktape.QueryRequest{Query: "SELECT id FROM test_statement_cache WHERE id < ?", Args: []any{i + 1}})
+			if err != nil {
+				t.Fatalf("failed to query: %v", err)
+			}
+			if len(rows) != i+1 {
+				t.Errorf("expected %d rows, got %d", i+1, len(rows))
+			}
+		}
+
+		stats := GetStatementCacheStats()
+		if stats.Hits != 2 || stats.Misses != 1 || stats.Size != 1 {
+			t.Errorf("expected 2 hits, 1 miss and 1 cached statement, got %+v", stats)
+		}
+	})
+
+	t.Run("least recently used statement is evicted", func(t *testing.T) {
+		for _, request := range []ducktape.QueryRequest{
+			{Query: "SELECT 1"},
+			{Query: "SELECT 2"},
+			{Query: "SELECT id FROM test_statement_cache WHERE id < ?", Args: []any{1}},
+		} {
+			if _, err := Query(ctx, dsn, request); err != nil {
+				t.Fatalf("failed to query: %v", err)
+			}
+		}
+
+		stats := GetStatementCacheStats()
+		if stats.Size != 2 || stats.Evictions != 2 {
+			t.Errorf("expected 2 cached statements after 2 evictions, got %+v", stats)
+		}
+	})
+
+	t.Run("writes are visible to cached statements", func(t *testing.T) {
+		_, err := Execute(ctx, dsn, ducktape.ExecuteRequest{
+			Statements: []ducktape.ExecuteStatement{{Query: "INSERT INTO test_statement_cache VALUES (-1)"}},
+		})
+		if err != nil {
+			t.Fatalf("failed to insert: %v", err)
+		}
+
+		rows, err := Query(ctx, dsn, ducktape.QueryRequest{Query: "SELECT id FROM test_statement_cache WHERE id < ?", Args: []any{0}})
+		if err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+		if len(rows) != 1 {
+			t.Errorf("expected the inserted row, got %v", rows)
+		}
+	})
+
+	t.Run("multiple statements are not cached", func(t *testing.T) {
+		before := GetStatementCacheStats()
+		rows, err := Query(ctx, dsn, ducktape.QueryRequest{Query: "SELECT 1; SELECT 2 AS two"})
+		if err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+		if len(rows) != 1 || rows[0]["two"] != int32(2) {
+			t.Errorf("expected the result of the last statement, got %v", rows)
+		}
+		if after := GetStatementCacheStats(); after.Size != before.Size || after.Evictions != before.Evictions {
+			t.Errorf("expected the cache to be unchanged, got %+v", after)
+		}
+	})
+
+	t.Run("invalid queries report the regular error", func(t *testing.T) {
+		if _, err := Query(ctx, dsn, ducktape.QueryRequest{Query: "SELECT * FROM missing_table"}); err == nil {
+			t.Error("expected an error for a missing table, got none")
+		}
+	})
+
+	t.Run("in-memory databases are not cached", func(t *testing.T) {
+		before := GetStatementCacheStats()
+		if _, err := Query(ctx, "", ducktape.QueryRequest{Query: "SELECT 1"}); err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+		if after := GetStatementCacheStats(); after.Hits+after.Misses != before.Hits+before.Misses {
+			t.Errorf("expected no cache lookup, got %+v", after)
+		}
+	})
+}
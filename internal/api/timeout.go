@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+)
+
+var ErrQueryTimeout = errors.New("query timed out")
+
+// withQueryTimeout bounds the request context by the smaller of the timeout requested through the
+// [ducktape.DuckDBTimeoutHeader] header and the server-wide [Options.MaxQueryDuration]. DuckDB interrupts the running
+// statement as soon as the context is done.
+func withQueryTimeout(r *http.Request) (context.Context, context.CancelFunc, error) {
+	timeout, err := queryTimeout(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := withTimeout(r.Context(), timeout)
+	return ctx, cancel, nil
+}
+
+// queryTimeout returns the smaller of the timeout requested through the [ducktape.DuckDBTimeoutHeader] header and the
+// server-wide [Options.MaxQueryDuration], zero means no limit.
+func queryTimeout(r *http.Request) (time.Duration, error) {
+	return requestTimeout(r, options.MaxQueryDuration)
+}
+
+// requestTimeout returns the smaller of the timeout requested through the [ducktape.DuckDBTimeoutHeader] header and
+// limit, zero means no limit.
+func requestTimeout(r *http.Request, limit time.Duration) (time.Duration, error) {
+	timeout := limit
+	if header := r.Header.Get(ducktape.DuckDBTimeoutHeader); header != "" {
+		requested, err := time.ParseDuration(header)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse the %q header: %w", ducktape.DuckDBTimeoutHeader, err)
+		}
+		if requested <= 0 {
+			return 0, fmt.Errorf("%q header must be positive, got %q", ducktape.DuckDBTimeoutHeader, header)
+		}
+		if timeout == 0 || requested < timeout {
+			timeout = requested
+		}
+	}
+	return timeout, nil
+}
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, timeout, fmt.Errorf("%w after %s", ErrQueryTimeout, timeout))
+}
+
+// contextError wraps err with the reason the request context ended, when it was ended by the query deadline or by
+// [CancelOperation], so callers can tell those apart from regular failures.
+func contextError(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+	if cause := context.Cause(ctx); errors.Is(cause, ErrQueryTimeout) || errors.Is(cause, ErrOperationCancelled) {
+		return fmt.Errorf("%w: %w", cause, err)
+	}
+	return err
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+	_ "github.com/duckdb/duckdb-go/v2"
+)
+
+func TestWithQueryTimeout(t *testing.T) {
+	t.Cleanup(func() { Configure(Options{}) })
+
+	t.Run("no limit", func(t *testing.T) {
+		Configure(Options{})
+		ctx, cancel, err := withQueryTimeout(httptest.NewRequest("POST", ducktape.QueryRoute, nil))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer cancel()
+
+		if _, ok := ctx.Deadline(); ok {
+			t.Error("expected no deadline")
+		}
+	})
+
+	t.Run("request timeout is capped by the server maximum", func(t *testing.T) {
+		Configure(Options{MaxQueryDuration: time.Second})
+		r := httptest.NewRequest("POST", ducktape.QueryRoute, nil)
+		r.Header.Set(ducktape.DuckDBTimeoutHeader, "1h")
+
+		ctx, cancel, err := withQueryTimeout(r)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer cancel()
+
+		deadline, ok := ctx.Deadline()
+		if !ok || time.Until(deadline) > time.Second {
+			t.Errorf("expected a deadline within 1s, got %v", deadline)
+		}
+	})
+
+	t.Run("shorter request timeout wins", func(t *testing.T) {
+		Configure(Options{MaxQueryDuration: time.Hour})
+		r := httptest.NewRequest("POST", ducktape.QueryRoute, nil)
+		r.Header.Set(ducktape.DuckDBTimeoutHeader, "50ms")
+
+		ctx, cancel, err := withQueryTimeout(r)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer cancel()
+
+		deadline, ok := ctx.Deadline()
+		if !ok || time.Until(deadline) > 50*time.Millisecond {
+			t.Errorf("expected a deadline within 50ms, got %v", deadline)
+		}
+	})
+
+	t.Run("invalid header", func(t *testing.T) {
+		Configure(Options{})
+		for _, value := range []string{"soon", "-1s", "0s"} {
+			r := httptest.NewRequest("POST", ducktape.QueryRoute, nil)
+			r.Header.Set(ducktape.DuckDBTimeoutHeader, value)
+			if _, _, err := withQueryTimeout(r); err == nil {
+				t.Errorf("expected error for %q, got none", value)
+			}
+		}
+	})
+}
+
+func TestQueryTimeoutInterruptsDuckDB(t *testing.T) {
+	Configure(Options{MaxQueryDuration: 100 * time.Millisecond})
+	t.Cleanup(func() { Configure(Options{}) })
+
+	ctx, cancel, err := withQueryTimeout(httptest.NewRequest("POST", ducktape.QueryRoute, nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer cancel()
+
+	start := time.Now()
+	_, err = Query(ctx, "", ducktape.QueryRequest{
+		Query: "SELECT count(*) FROM range(1000000000000) a",
+	})
+	err = contextError(ctx, err)
+	if !errors.Is(err, ErrQueryTimeout) {
+		t.Fatalf("expected ErrQueryTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the query to be interrupted quickly, took %s", elapsed)
+	}
+
+	if err := contextError(context.Background(), errors.New("boom")); errors.Is(err, ErrQueryTimeout) {
+		t.Error("expected errors without a deadline to be left untouched")
+	}
+}
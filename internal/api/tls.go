@@ -0,0 +1,152 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertReloader serves a TLS certificate from files and picks up new versions of them, so certificates can be rotated
+// without restarting the server.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mu          sync.RWMutex
+	certificate *tls.Certificate
+	modTime     time.Time
+}
+
+// NewCertReloader loads the certificate and private key, both PEM-encoded. Call [CertReloader.Watch] to reload them
+// when they change.
+func NewCertReloader(certFile string, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate returns the current certificate, it is meant for [tls.Config.GetCertificate].
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.certificate, nil
+}
+
+// Watch checks the files for changes every interval until the context is done. A certificate that fails to load is
+// logged and the previous one keeps being served.
+func (r *CertReloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := r.reload()
+			if err != nil {
+				slog.Error("failed to reload the TLS certificate", slog.String("certFile", r.certFile), slog.Any("error", err))
+			} else if reloaded {
+				slog.Info("reloaded the TLS certificate", slog.String("certFile", r.certFile))
+			}
+		}
+	}
+}
+
+// reload loads the files if either was modified since they were last loaded.
+func (r *CertReloader) reload() (bool, error) {
+	modTime, err := latestModTime(r.certFile, r.keyFile)
+	if err != nil {
+		return false, err
+	}
+	r.mu.RLock()
+	unchanged := r.certificate != nil && modTime.Equal(r.modTime)
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to load the TLS certificate: %w", err)
+	}
+	r.mu.Lock()
+	r.certificate = &certificate
+	r.modTime = modTime
+	r.mu.Unlock()
+	return true, nil
+}
+
+func latestModTime(paths ...string) (time.Time, error) {
+	var latest time.Time
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to stat %q: %w", path, err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// NewTLSConfig returns the TLS configuration of the server, which offers HTTP/2 and HTTP/1.1 through ALPN. When a
+// client CA file is given, client certificates are verified against it, and required if requireClientCert is set.
+func NewTLSConfig(certificates *CertReloader, clientCAFile string, requireClientCert bool) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		NextProtos:     []string{"h2", "http/1.1"},
+		GetCertificate: certificates.GetCertificate,
+	}
+	if clientCAFile == "" {
+		if requireClientCert {
+			return nil, errors.New("a client CA is required to verify client certificates")
+		}
+		return config, nil
+	}
+
+	pem, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the client CA file: %w", err)
+	}
+	config.ClientCAs = x509.NewCertPool()
+	if !config.ClientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in the client CA file %q", clientCAFile)
+	}
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+	if requireClientCert {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// ClientCertAuthenticator accepts the client certificates verified during the TLS handshake, see [NewTLSConfig]. The
+// common name of the certificate names the principal, or its first URI or DNS name when it has none.
+type ClientCertAuthenticator struct{}
+
+func (ClientCertAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return Principal{}, ErrNoCredentials
+	}
+	certificate := r.TLS.VerifiedChains[0][0]
+	name := certificate.Subject.CommonName
+	if name == "" && len(certificate.URIs) > 0 {
+		name = certificate.URIs[0].String()
+	}
+	if name == "" && len(certificate.DNSNames) > 0 {
+		name = certificate.DNSNames[0]
+	}
+	if name == "" {
+		return Principal{}, fmt.Errorf("%w: the client certificate has no common name, URI or DNS name", ErrInvalidCredentials)
+	}
+	return Principal{Name: name, Method: "mtls"}, nil
+}
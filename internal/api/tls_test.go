@@ -0,0 +1,205 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+	"golang.org/x/net/http2"
+)
+
+type testCertificate struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	pem         []byte
+	keyPEM      []byte
+}
+
+// issueCertificate signs a certificate with the parent, or self-signs a CA without one.
+func issueCertificate(t *testing.T, template *x509.Certificate, parent *testCertificate) *testCertificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.certificate, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	return &testCertificate{
+		certificate: certificate,
+		key:         key,
+		pem:         pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:      pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func (c *testCertificate) keyPair(t *testing.T) tls.Certificate {
+	t.Helper()
+	pair, err := tls.X509KeyPair(c.pem, c.keyPEM)
+	if err != nil {
+		t.Fatalf("failed to load key pair: %v", err)
+	}
+	return pair
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("failed to write %q: %v", path, err)
+	}
+}
+
+func TestTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := issueCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: "ca"}}, nil)
+	serverTemplate := func() *x509.Certificate {
+		return &x509.Certificate{
+			Subject:     pkix.Name{CommonName: "ducktape"},
+			IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}
+	}
+	server := issueCertificate(t, serverTemplate(), ca)
+	client := issueCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "etl"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+	untrusted := issueCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "etl"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, issueCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: "other"}}, nil))
+
+	certFile, keyFile, caFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), filepath.Join(dir, "ca.pem")
+	writeFile(t, certFile, server.pem)
+	writeFile(t, keyFile, server.keyPEM)
+	writeFile(t, caFile, ca.pem)
+
+	certificates, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("failed to load certificate: %v", err)
+	}
+	config, err := NewTLSConfig(certificates, caFile, false)
+	if err != nil {
+		t.Fatalf("failed to configure TLS: %v", err)
+	}
+
+	Configure(Options{Policy: &Policy{Principals: map[string]PrincipalPolicy{
+		"etl": {DSNs: []string{"*"}, Operations: []Operation{OperationPing}},
+	}}})
+	t.Cleanup(func() { Configure(Options{}) })
+	mux := http.NewServeMux()
+	RegisterApiRoutes(mux)
+	httpServer := &http.Server{Handler: Authenticate(mux, ClientCertAuthenticator{}), TLSConfig: config}
+	if err := http2.ConfigureServer(httpServer, &http2.Server{}); err != nil {
+		t.Fatalf("failed to configure HTTP/2: %v", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go httpServer.ServeTLS(listener, "", "")
+	t.Cleanup(func() { httpServer.Close() })
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.certificate)
+	baseURL := "https://" + listener.Addr().String()
+	ctx := context.Background()
+
+	t.Run("client certificate", func(t *testing.T) {
+		c := ducktape.NewClient(baseURL, ducktape.WithRootCAs(roots), ducktape.WithClientCertificate(client.keyPair(t)))
+		if err := c.Ping(ctx, ":memory:"); err != nil {
+			t.Errorf("expected the client certificate to authenticate the request, got %v", err)
+		}
+	})
+
+	t.Run("no client certificate", func(t *testing.T) {
+		c := ducktape.NewClient(baseURL, ducktape.WithRootCAs(roots))
+		if err := c.Ping(ctx, ":memory:"); err == nil {
+			t.Error("expected the request to be unauthorized")
+		}
+	})
+
+	t.Run("untrusted client certificate", func(t *testing.T) {
+		c := ducktape.NewClient(baseURL, ducktape.WithRootCAs(roots), ducktape.WithClientCertificate(untrusted.keyPair(t)))
+		if err := c.Ping(ctx, ":memory:"); err == nil {
+			t.Error("expected the untrusted certificate to be rejected")
+		}
+	})
+
+	t.Run("untrusted server", func(t *testing.T) {
+		c := ducktape.NewClient(baseURL, ducktape.WithClientCertificate(client.keyPair(t)))
+		if err := c.Ping(ctx, ":memory:"); err == nil {
+			t.Error("expected the server certificate to be rejected")
+		}
+	})
+
+	t.Run("reload", func(t *testing.T) {
+		rotated := issueCertificate(t, serverTemplate(), ca)
+		writeFile(t, certFile, rotated.pem)
+		writeFile(t, keyFile, rotated.keyPEM)
+		later := time.Now().Add(time.Minute)
+		for _, path := range []string{certFile, keyFile} {
+			if err := os.Chtimes(path, later, later); err != nil {
+				t.Fatalf("failed to touch %q: %v", path, err)
+			}
+		}
+		if reloaded, err := certificates.reload(); err != nil || !reloaded {
+			t.Fatalf("expected the certificate to be reloaded, got %v (%v)", reloaded, err)
+		}
+		if reloaded, err := certificates.reload(); err != nil || reloaded {
+			t.Errorf("expected an unchanged certificate not to be reloaded, got %v (%v)", reloaded, err)
+		}
+
+		c := ducktape.NewClient(baseURL, ducktape.WithRootCAs(roots), ducktape.WithClientCertificate(client.keyPair(t)))
+		if err := c.Ping(ctx, ":memory:"); err != nil {
+			t.Fatalf("failed to ping: %v", err)
+		}
+		current, _ := certificates.GetCertificate(nil)
+		if !current.Leaf.Equal(rotated.certificate) {
+			t.Error("expected the rotated certificate to be served")
+		}
+
+		writeFile(t, keyFile, []byte("not a key"))
+		evenLater := later.Add(time.Minute)
+		os.Chtimes(keyFile, evenLater, evenLater)
+		if _, err := certificates.reload(); err == nil {
+			t.Error("expected an invalid key to fail to load")
+		}
+		if current, _ := certificates.GetCertificate(nil); !current.Leaf.Equal(rotated.certificate) {
+			t.Error("expected the previous certificate to keep being served")
+		}
+	})
+}
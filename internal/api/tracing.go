@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+)
+
+// tracer creates the spans of the server through the global tracer provider, which does nothing until one is set with
+// [otel.SetTracerProvider].
+var tracer = otel.Tracer("github.com/artie-labs/ducktape/internal/api")
+
+// traceRoute starts a server span for each request of a route, continuing the trace that the client sent in its
+// headers, e.g. the W3C traceparent header, through the global propagator.
+func traceRoute(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		principal, _ := PrincipalFromContext(ctx)
+		dsn := dsnPath(r.Header.Get(ducktape.DuckDBConnectionStringHeader))
+		ctx, span := tracer.Start(ctx, r.Pattern, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("http.route", r.Pattern),
+			attribute.String("url.path", r.URL.Path),
+			attribute.String("client.address", r.RemoteAddr),
+			attribute.String("enduser.id", principal.Name),
+			attribute.String("db.namespace", dsn),
+		))
+		defer span.End()
+
+		recorder := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+		handler(recorder, r.WithContext(ctx))
+		span.SetAttributes(attribute.Int("http.response.status_code", recorder.status))
+		if recorder.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(recorder.status))
+		}
+	}
+}
+
+// startSpan starts a span for a step of the request in ctx, e.g. "duckdb.open". It must be ended with [endSpan].
+func startSpan(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(append(attributes, attribute.String("db.system.name", "duckdb"))...))
+}
+
+// queryText is the span attribute of the SQL that a span runs. Arguments are bound separately, so they are not part of
+// it.
+func queryText(query string) attribute.KeyValue {
+	return attribute.String("db.query.text", query)
+}
+
+// endSpan ends a span started by [startSpan], recording the error that the step failed with, if any.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
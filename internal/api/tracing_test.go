@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+	_ "github.com/duckdb/duckdb-go/v2"
+)
+
+func TestTracing(t *testing.T) {
+	// The tracer of the package keeps the first provider that is set, so this provider stays for the other tests
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+		exporter.Reset()
+	})
+
+	mux := http.NewServeMux()
+	RegisterApiRoutes(mux)
+	server := httptest.NewUnstartedServer(mux)
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	t.Cleanup(server.Close)
+
+	spans := func() map[string]tracetest.SpanStub {
+		result := make(map[string]tracetest.SpanStub)
+		for _, span := range exporter.GetSpans() {
+			result[span.Name] = span
+		}
+		exporter.Reset()
+		return result
+	}
+
+	t.Run("client", func(t *testing.T) {
+		ctx, parent := provider.Tracer("test").Start(context.Background(), "client")
+		err := ducktape.NewClient(server.URL).Ping(ctx, ":memory:")
+		parent.End()
+		if err != nil {
+			t.Fatalf("failed to ping: %v", err)
+		}
+
+		recorded := spans()
+		handler, ok := recorded["GET "+ducktape.PingRoute]
+		if !ok {
+			t.Fatalf("expected a span for the handler, got %v", recorded)
+		}
+		if handler.SpanContext.TraceID() != parent.SpanContext().TraceID() || handler.Parent.SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("expected the handler span to continue the trace of the client")
+		}
+		if open := recorded["duckdb.open"]; open.Parent.SpanID() != handler.SpanContext.SpanID() {
+			t.Errorf("expected a child span for opening the database, got %v", recorded)
+		}
+	})
+
+	t.Run("append", func(t *testing.T) {
+		dsn := "test_tracing.db"
+		t.Cleanup(func() { os.Remove(dsn) })
+		if _, err := Execute(context.Background(), dsn, ducktape.ExecuteRequest{
+			Statements: []ducktape.ExecuteStatement{{Query: "CREATE TABLE test_tracing (id INTEGER)"}},
+		}); err != nil {
+			t.Fatalf("failed to create table: %v", err)
+		}
+		spans()
+
+		traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+		r := httptest.NewRequest("POST", ducktape.AppendRoute, strings.NewReader(`{"rv":[1]}`+"\n"))
+		r.ProtoMajor = 2
+		r.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+		r.Header.Set(ducktape.DuckDBConnectionStringHeader, dsn)
+		r.Header.Set(ducktape.DuckDBDatabaseHeader, "test_tracing")
+		r.Header.Set(ducktape.DuckDBTableHeader, "test_tracing")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("failed to append: %d %s", w.Code, w.Body.String())
+		}
+
+		recorded := spans()
+		for _, name := range []string{"POST " + ducktape.AppendRoute, "duckdb.open", "duckdb.column_metadata", "duckdb.appender.flush"} {
+			span, ok := recorded[name]
+			if !ok {
+				t.Errorf("expected a %q span, got %v", name, recorded)
+				continue
+			}
+			if span.SpanContext.TraceID().String() != traceID {
+				t.Errorf("expected the %q span to belong to the trace of the traceparent header", name)
+			}
+		}
+	})
+}
@@ -0,0 +1,321 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+)
+
+const defaultTransactionIdleTimeout = 5 * time.Minute
+
+var (
+	ErrTransactionNotFound    = errors.New("transaction not found")
+	ErrTransactionDSNMismatch = errors.New("transaction was started with a different connection string")
+)
+
+var transactions = newTransactionStore(defaultTransactionIdleTimeout)
+
+// transaction is a DuckDB transaction that outlives the request that started it.
+type transaction struct {
+	id  string
+	dsn string
+	db  *sql.DB
+	tx  *sql.Tx
+	// principal is the caller that began the transaction, only it can use the transaction.
+	principal string
+
+	// Guarded by transactionStore.mu
+	inFlight  int
+	idleTimer *time.Timer
+}
+
+// transactionStore holds open transactions and rolls back the ones that have been idle for longer than idleTimeout.
+type transactionStore struct {
+	idleTimeout time.Duration
+
+	mu           sync.Mutex
+	transactions map[string]*transaction
+}
+
+func newTransactionStore(idleTimeout time.Duration) *transactionStore {
+	return &transactionStore{
+		idleTimeout:  idleTimeout,
+		transactions: make(map[string]*transaction),
+	}
+}
+
+func (s *transactionStore) begin(ctx context.Context, dsn string) (string, error) {
+	if shuttingDown.Load() {
+		return "", fmt.Errorf("%w: transactions cannot begin", ErrShuttingDown)
+	}
+	db, err := openDB(ctx, dsn, "transaction")
+	if err != nil {
+		return "", err
+	}
+
+	// The transaction must not be bound to the request context, otherwise it would be rolled back as soon as the begin request completes.
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		db.Close()
+		return "", fmt.Errorf("failed to begin a transaction(%q): %w", "duckdb", err)
+	}
+
+	t := &transaction{id: rand.Text(), dsn: dsn, db: db, tx: tx, principal: principalName(ctx)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transactions[t.id] = t
+	t.idleTimer = time.AfterFunc(s.idleTimeout, func() { s.expire(t) })
+	return t.id, nil
+}
+
+// acquire looks up a transaction and pauses its idle timer until [transactionStore.release] is called.
+func (s *transactionStore) acquire(ctx context.Context, id string, dsn string) (*transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, err := s.lookup(ctx, id, dsn)
+	if err != nil {
+		return nil, err
+	}
+	t.inFlight++
+	t.idleTimer.Stop()
+	return t, nil
+}
+
+func (s *transactionStore) release(t *transaction) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t.inFlight--
+	if t.inFlight == 0 && s.transactions[t.id] == t {
+		t.idleTimer.Reset(s.idleTimeout)
+	}
+}
+
+// remove detaches a transaction from the store so it can be committed or rolled back.
+func (s *transactionStore) remove(ctx context.Context, id string, dsn string) (*transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, err := s.lookup(ctx, id, dsn)
+	if err != nil {
+		return nil, err
+	}
+	delete(s.transactions, id)
+	t.idleTimer.Stop()
+	return t, nil
+}
+
+// lookup must be called with s.mu held.
+func (s *transactionStore) lookup(ctx context.Context, id string, dsn string) (*transaction, error) {
+	t, ok := s.transactions[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrTransactionNotFound, id)
+	}
+	if err := checkOwner(ctx, t.principal); err != nil {
+		return nil, fmt.Errorf("transaction %q: %w", id, err)
+	}
+	// An alias and its path, or the same path with other options, open the same database
+	if dsn != "" && databaseKey(dsn) != databaseKey(t.dsn) {
+		return nil, fmt.Errorf("%w: %q", ErrTransactionDSNMismatch, id)
+	}
+	return t, nil
+}
+
+// close rolls back every open transaction, the ones that are in use are rolled back as well since their requests are
+// expected to have been cancelled.
+func (s *transactionStore) close() {
+	s.mu.Lock()
+	open := make([]*transaction, 0, len(s.transactions))
+	for _, t := range s.transactions {
+		t.idleTimer.Stop()
+		open = append(open, t)
+	}
+	clear(s.transactions)
+	s.mu.Unlock()
+
+	for _, t := range open {
+		slog.Warn("rolling back open transaction", slog.String("transactionId", t.id))
+		if err := t.tx.Rollback(); err != nil {
+			slog.Error("failed to roll back open transaction", slog.String("transactionId", t.id), slog.Any("error", err))
+		}
+		checkpointDB(t.db, t.dsn)
+		t.db.Close()
+	}
+}
+
+func (s *transactionStore) expire(t *transaction) {
+	s.mu.Lock()
+	if s.transactions[t.id] != t || t.inFlight > 0 {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.transactions, t.id)
+	s.mu.Unlock()
+
+	slog.Warn("rolling back idle transaction", slog.String("transactionId", t.id), slog.Duration("idleTimeout", s.idleTimeout))
+	if err := t.tx.Rollback(); err != nil {
+		slog.Error("failed to roll back idle transaction", slog.String("transactionId", t.id), slog.Any("error", err))
+	}
+	t.db.Close()
+}
+
+func (s *transactionStore) commit(ctx context.Context, id string, dsn string) error {
+	t, err := s.remove(ctx, id, dsn)
+	if err != nil {
+		return err
+	}
+	defer t.db.Close()
+	defer results.invalidate(t.dsn)
+
+	if err := t.tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit the transaction: %w", err)
+	}
+	return nil
+}
+
+func (s *transactionStore) rollback(ctx context.Context, id string, dsn string) error {
+	t, err := s.remove(ctx, id, dsn)
+	if err != nil {
+		return err
+	}
+	defer t.db.Close()
+
+	if err := t.tx.Rollback(); err != nil {
+		return fmt.Errorf("failed to roll back the transaction: %w", err)
+	}
+	return nil
+}
+
+func (s *transactionStore) execute(ctx context.Context, id string, dsn string, request ducktape.ExecuteRequest) (sql.Result, error) {
+	if len(request.Statements) == 0 {
+		return nil, fmt.Errorf("at least one statement is required")
+	}
+
+	t, err := s.acquire(ctx, id, dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer s.release(t)
+
+	if err := checkReadOnly(ctx, t.dsn, statementQueries(request.Statements)...); err != nil {
+		return nil, err
+	}
+	response, err := executeStatements(ctx, t.tx, request)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (s *transactionStore) query(ctx context.Context, id string, dsn string, request ducktape.QueryRequest) (ducktape.QueryResponse, error) {
+	t, err := s.acquire(ctx, id, dsn)
+	if err != nil {
+		return ducktape.QueryResponse{}, err
+	}
+	defer s.release(t)
+
+	if err := checkReadOnly(ctx, t.dsn, request.Query); err != nil {
+		return ducktape.QueryResponse{}, err
+	}
+	return queryObjects(ctx, t.tx, request)
+}
+
+// BeginTransaction starts a transaction that subsequent calls can reference by the returned ID.
+// Transactions that are not used for longer than the idle timeout are rolled back automatically.
+func BeginTransaction(dsn string) (string, error) {
+	return transactions.begin(context.Background(), dsn)
+}
+
+// ExecuteInTransaction runs the statements inside an open transaction without committing it.
+// An empty DSN skips the check that the transaction was started against the same connection string.
+func ExecuteInTransaction(ctx context.Context, transactionID string, dsn string, request ducktape.ExecuteRequest) (sql.Result, error) {
+	return transactions.execute(ctx, transactionID, dsn, request)
+}
+
+// QueryInTransaction runs a query inside an open transaction, seeing its uncommitted writes.
+// The server-wide result limits apply, pagination is not supported inside a transaction.
+func QueryInTransaction(ctx context.Context, transactionID string, dsn string, request ducktape.QueryRequest) (ducktape.QueryResponse, error) {
+	return transactions.query(ctx, transactionID, dsn, request)
+}
+
+func CommitTransaction(transactionID string, dsn string) error {
+	return transactions.commit(context.Background(), transactionID, dsn)
+}
+
+func RollbackTransaction(transactionID string, dsn string) error {
+	return transactions.rollback(context.Background(), transactionID, dsn)
+}
+
+func handleBeginTransaction(w http.ResponseWriter, r *http.Request) {
+	dsn := r.Header.Get(ducktape.DuckDBConnectionStringHeader)
+	if dsn == "" {
+		err := fmt.Errorf("%q header is required", ducktape.DuckDBConnectionStringHeader)
+		errMsg := err.Error()
+		handleBadRequestJSON(w, ducktape.BeginTransactionResponse{Error: &errMsg}, err)
+		return
+	}
+
+	transactionID, err := transactions.begin(r.Context(), dsn)
+	if err != nil {
+		errMsg := err.Error()
+		handleErrorJSON(w, ducktape.BeginTransactionResponse{Error: &errMsg}, err)
+		return
+	}
+
+	body, err := json.Marshal(ducktape.BeginTransactionResponse{TransactionID: transactionID})
+	if err != nil {
+		err := fmt.Errorf("failed to marshal the response: %v", err)
+		errMsg := err.Error()
+		handleInternalServerErrorJSON(w, ducktape.BeginTransactionResponse{Error: &errMsg}, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+	LoggerFromContext(r.Context()).Debug("began transaction", slog.String("transactionId", transactionID))
+}
+
+func handleCommitTransaction(w http.ResponseWriter, r *http.Request) {
+	handleEndTransaction(w, r, transactions.commit)
+}
+
+func handleRollbackTransaction(w http.ResponseWriter, r *http.Request) {
+	handleEndTransaction(w, r, transactions.rollback)
+}
+
+func handleEndTransaction(w http.ResponseWriter, r *http.Request, end func(ctx context.Context, transactionID string, dsn string) error) {
+	transactionID := r.Header.Get(ducktape.DuckDBTransactionIDHeader)
+	if transactionID == "" {
+		err := fmt.Errorf("%q header is required", ducktape.DuckDBTransactionIDHeader)
+		errMsg := err.Error()
+		handleBadRequestJSON(w, ducktape.TransactionResponse{Error: &errMsg}, err)
+		return
+	}
+
+	if err := end(r.Context(), transactionID, r.Header.Get(ducktape.DuckDBConnectionStringHeader)); err != nil {
+		errMsg := err.Error()
+		handleErrorJSON(w, ducktape.TransactionResponse{Error: &errMsg}, err)
+		return
+	}
+
+	body, err := json.Marshal(ducktape.TransactionResponse{})
+	if err != nil {
+		err := fmt.Errorf("failed to marshal the response: %v", err)
+		errMsg := err.Error()
+		handleInternalServerErrorJSON(w, ducktape.TransactionResponse{Error: &errMsg}, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
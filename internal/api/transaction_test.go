@@ -0,0 +1,215 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+	_ "github.com/duckdb/duckdb-go/v2"
+)
+
+func TestTransaction(t *testing.T) {
+	ctx := context.Background()
+
+	createTable := func(t *testing.T, dsn string, table string) {
+		_, err := Execute(ctx, dsn, ducktape.ExecuteRequest{
+			Statements: []ducktape.ExecuteStatement{
+				{Query: "CREATE TABLE " + table + " (id INTEGER, value VARCHAR)"},
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to create table: %v", err)
+		}
+	}
+
+	countRows := func(t *testing.T, dsn string, table string) int {
+		rows, err := Query(ctx, dsn, ducktape.QueryRequest{Query: "SELECT * FROM " + table})
+		if err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+		return len(rows)
+	}
+
+	t.Run("commit makes writes visible", func(t *testing.T) {
+		dsn := "test_tx_commit.db"
+		t.Cleanup(func() { os.Remove(dsn) })
+		createTable(t, dsn, "test_tx_commit")
+
+		id, err := BeginTransaction(dsn)
+		if err != nil {
+			t.Fatalf("failed to begin transaction: %v", err)
+		}
+
+		result, err := ExecuteInTransaction(ctx, id, dsn, ducktape.ExecuteRequest{
+			Statements: []ducktape.ExecuteStatement{
+				{Query: "INSERT INTO test_tx_commit VALUES (?, ?)", Args: []any{1, "a"}},
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to execute in transaction: %v", err)
+		}
+		if rowsAffected, _ := result.RowsAffected(); rowsAffected != 1 {
+			t.Errorf("expected 1 row affected, got %d", rowsAffected)
+		}
+
+		// Reads inside the transaction see its own writes
+		response, err := QueryInTransaction(ctx, id, dsn, ducktape.QueryRequest{Query: "SELECT * FROM test_tx_commit"})
+		if err != nil {
+			t.Fatalf("failed to query in transaction: %v", err)
+		}
+		if len(response.Rows) != 1 {
+			t.Errorf("expected 1 row inside the transaction, got %d", len(response.Rows))
+		}
+
+		if err := CommitTransaction(id, dsn); err != nil {
+			t.Fatalf("failed to commit: %v", err)
+		}
+
+		if count := countRows(t, dsn, "test_tx_commit"); count != 1 {
+			t.Errorf("expected 1 row after commit, got %d", count)
+		}
+
+		if err := CommitTransaction(id, dsn); !errors.Is(err, ErrTransactionNotFound) {
+			t.Errorf("expected ErrTransactionNotFound on second commit, got %v", err)
+		}
+	})
+
+	t.Run("rollback discards writes", func(t *testing.T) {
+		dsn := "test_tx_rollback.db"
+		t.Cleanup(func() { os.Remove(dsn) })
+		createTable(t, dsn, "test_tx_rollback")
+
+		id, err := BeginTransaction(dsn)
+		if err != nil {
+			t.Fatalf("failed to begin transaction: %v", err)
+		}
+
+		_, err = ExecuteInTransaction(ctx, id, dsn, ducktape.ExecuteRequest{
+			Statements: []ducktape.ExecuteStatement{
+				{Query: "INSERT INTO test_tx_rollback VALUES (1, 'a'), (2, 'b')"},
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to execute in transaction: %v", err)
+		}
+
+		if err := RollbackTransaction(id, dsn); err != nil {
+			t.Fatalf("failed to roll back: %v", err)
+		}
+
+		if count := countRows(t, dsn, "test_tx_rollback"); count != 0 {
+			t.Errorf("expected 0 rows after rollback, got %d", count)
+		}
+	})
+
+	t.Run("unknown transaction", func(t *testing.T) {
+		_, err := QueryInTransaction(ctx, "does-not-exist", "", ducktape.QueryRequest{Query: "SELECT 1"})
+		if !errors.Is(err, ErrTransactionNotFound) {
+			t.Errorf("expected ErrTransactionNotFound, got %v", err)
+		}
+	})
+
+	t.Run("mismatched DSN", func(t *testing.T) {
+		dsn := "test_tx_mismatch.db"
+		t.Cleanup(func() { os.Remove(dsn) })
+
+		id, err := BeginTransaction(dsn)
+		if err != nil {
+			t.Fatalf("failed to begin transaction: %v", err)
+		}
+		t.Cleanup(func() { RollbackTransaction(id, "") })
+
+		_, err = QueryInTransaction(ctx, id, "other.db", ducktape.QueryRequest{Query: "SELECT 1"})
+		if !errors.Is(err, ErrTransactionDSNMismatch) {
+			t.Errorf("expected ErrTransactionDSNMismatch, got %v", err)
+		}
+	})
+
+	t.Run("equivalent DSN", func(t *testing.T) {
+		dataDir := t.TempDir()
+		Configure(Options{DataDir: dataDir, DSNAliases: map[string]string{"tx": "test_tx_alias.db"}})
+		t.Cleanup(func() { Configure(Options{}) })
+
+		id, err := BeginTransaction("tx?threads=1&memory_limit=1GB")
+		if err != nil {
+			t.Fatalf("failed to begin transaction: %v", err)
+		}
+		t.Cleanup(func() { RollbackTransaction(id, "") })
+
+		for _, dsn := range []string{"test_tx_alias.db", filepath.Join(dataDir, "test_tx_alias.db") + "?memory_limit=1GB&threads=1"} {
+			if _, err := QueryInTransaction(ctx, id, dsn, ducktape.QueryRequest{Query: "SELECT 1"}); err != nil {
+				t.Errorf("expected %q to match the transaction, got %v", dsn, err)
+			}
+		}
+	})
+
+	t.Run("denied DSN", func(t *testing.T) {
+		Configure(Options{DataDir: t.TempDir()})
+		t.Cleanup(func() { Configure(Options{}) })
+
+		r := httptest.NewRequest("POST", ducktape.BeginTransactionRoute, nil)
+		r.Header.Set(ducktape.DuckDBConnectionStringHeader, "../test_tx_denied.db")
+		w := httptest.NewRecorder()
+		handleBeginTransaction(w, r)
+		if w.Code != 403 {
+			t.Errorf("expected status 403, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("transaction of another principal", func(t *testing.T) {
+		dsn := "test_tx_principal.db"
+		t.Cleanup(func() { os.Remove(dsn) })
+
+		alice := WithPrincipal(ctx, Principal{Name: "alice"})
+		id, err := transactions.begin(alice, dsn)
+		if err != nil {
+			t.Fatalf("failed to begin transaction: %v", err)
+		}
+
+		bob := WithPrincipal(ctx, Principal{Name: "bob"})
+		if _, err := QueryInTransaction(bob, id, "", ducktape.QueryRequest{Query: "SELECT 1"}); !errors.Is(err, ErrForbidden) {
+			t.Errorf("expected ErrForbidden for a query, got %v", err)
+		}
+		if err := transactions.rollback(bob, id, ""); !errors.Is(err, ErrForbidden) {
+			t.Errorf("expected ErrForbidden for a rollback, got %v", err)
+		}
+		if err := transactions.rollback(alice, id, ""); err != nil {
+			t.Errorf("expected the owner to roll back, got %v", err)
+		}
+	})
+
+	t.Run("idle transaction is rolled back", func(t *testing.T) {
+		dsn := "test_tx_idle.db"
+		t.Cleanup(func() { os.Remove(dsn) })
+		createTable(t, dsn, "test_tx_idle")
+
+		store := newTransactionStore(50 * time.Millisecond)
+		id, err := store.begin(context.Background(), dsn)
+		if err != nil {
+			t.Fatalf("failed to begin transaction: %v", err)
+		}
+
+		_, err = store.execute(ctx, id, dsn, ducktape.ExecuteRequest{
+			Statements: []ducktape.ExecuteStatement{
+				{Query: "INSERT INTO test_tx_idle VALUES (1, 'a')"},
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to execute in transaction: %v", err)
+		}
+
+		time.Sleep(200 * time.Millisecond)
+
+		if err := store.commit(ctx, id, dsn); !errors.Is(err, ErrTransactionNotFound) {
+			t.Errorf("expected ErrTransactionNotFound after idle timeout, got %v", err)
+		}
+		if count := countRows(t, dsn, "test_tx_idle"); count != 0 {
+			t.Errorf("expected 0 rows after idle rollback, got %d", count)
+		}
+	})
+}
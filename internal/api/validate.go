@@ -0,0 +1,217 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+	"github.com/duckdb/duckdb-go/v2"
+)
+
+var statementTypeNames = map[duckdb.StmtType]string{
+	duckdb.STATEMENT_TYPE_SELECT:       "SELECT",
+	duckdb.STATEMENT_TYPE_INSERT:       "INSERT",
+	duckdb.STATEMENT_TYPE_UPDATE:       "UPDATE",
+	duckdb.STATEMENT_TYPE_EXPLAIN:      "EXPLAIN",
+	duckdb.STATEMENT_TYPE_DELETE:       "DELETE",
+	duckdb.STATEMENT_TYPE_PREPARE:      "PREPARE",
+	duckdb.STATEMENT_TYPE_CREATE:       "CREATE",
+	duckdb.STATEMENT_TYPE_EXECUTE:      "EXECUTE",
+	duckdb.STATEMENT_TYPE_ALTER:        "ALTER",
+	duckdb.STATEMENT_TYPE_TRANSACTION:  "TRANSACTION",
+	duckdb.STATEMENT_TYPE_COPY:         "COPY",
+	duckdb.STATEMENT_TYPE_ANALYZE:      "ANALYZE",
+	duckdb.STATEMENT_TYPE_VARIABLE_SET: "VARIABLE_SET",
+	duckdb.STATEMENT_TYPE_CREATE_FUNC:  "CREATE_FUNC",
+	duckdb.STATEMENT_TYPE_DROP:         "DROP",
+	duckdb.STATEMENT_TYPE_EXPORT:       "EXPORT",
+	duckdb.STATEMENT_TYPE_PRAGMA:       "PRAGMA",
+	duckdb.STATEMENT_TYPE_VACUUM:       "VACUUM",
+	duckdb.STATEMENT_TYPE_CALL:         "CALL",
+	duckdb.STATEMENT_TYPE_SET:          "SET",
+	duckdb.STATEMENT_TYPE_LOAD:         "LOAD",
+	duckdb.STATEMENT_TYPE_RELATION:     "RELATION",
+	duckdb.STATEMENT_TYPE_EXTENSION:    "EXTENSION",
+	duckdb.STATEMENT_TYPE_LOGICAL_PLAN: "LOGICAL_PLAN",
+	duckdb.STATEMENT_TYPE_ATTACH:       "ATTACH",
+	duckdb.STATEMENT_TYPE_DETACH:       "DETACH",
+	duckdb.STATEMENT_TYPE_MULTI:        "MULTI",
+}
+
+// catalogOperators are the plan operators of the statements that [Validate] applies, so that later statements in the
+// batch bind against the objects they create or drop. They only change the catalog, which the rolled back transaction
+// undoes. Statements that process data (e.g. CREATE TABLE AS, CREATE INDEX or ALTER TABLE, which may rewrite the table)
+// or that are not transactional (e.g. CREATE PERSISTENT SECRET, which cannot be explained) are only bound.
+var catalogOperators = map[string]bool{
+	"CREATE_TABLE":    true,
+	"CREATE_VIEW":     true,
+	"CREATE_SCHEMA":   true,
+	"CREATE_MACRO":    true,
+	"CREATE_SEQUENCE": true,
+	"CREATE_TYPE":     true,
+	"DROP":            true,
+}
+
+// Validate parses and binds each statement without running it.
+// Catalog-only statements (see [catalogOperators]) are applied inside a transaction that is always rolled back, so later
+// statements in the batch can bind against the tables and views they create. Nothing else is executed and nothing is
+// committed.
+func Validate(ctx context.Context, dsn string, statements []ducktape.ExecuteStatement) ([]ducktape.StatementValidation, error) {
+	if len(statements) == 0 {
+		return nil, fmt.Errorf("at least one statement is required")
+	}
+
+	db, err := openDB(ctx, dsn, "validate")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get a connection for validate(%q): %w", "duckdb", err)
+	}
+	defer conn.Close()
+
+	session := &validationSession{conn: conn}
+	if err := session.begin(ctx); err != nil {
+		return nil, err
+	}
+	defer conn.ExecContext(context.Background(), "ROLLBACK")
+
+	validations := make([]ducktape.StatementValidation, len(statements))
+	for i, statement := range statements {
+		validation, apply := validateStatement(ctx, conn, i, statement.Query)
+		if validation.Valid && apply {
+			if _, err := conn.ExecContext(ctx, statement.Query); err != nil {
+				errMsg := err.Error()
+				validation.Valid = false
+				validation.Error = &errMsg
+			} else {
+				session.applied = append(session.applied, statement.Query)
+			}
+		}
+		if !validation.Valid {
+			// DuckDB has no savepoints: the error may have aborted the transaction, so the statements applied before it
+			// are applied again in a new one.
+			if err := session.restart(ctx); err != nil {
+				return nil, err
+			}
+		}
+		validations[i] = validation
+	}
+	return validations, nil
+}
+
+// validationSession is the transaction of [Validate] with the catalog statements applied to it so far.
+type validationSession struct {
+	conn    *sql.Conn
+	applied []string
+}
+
+func (s *validationSession) begin(ctx context.Context) error {
+	if _, err := s.conn.ExecContext(ctx, "BEGIN TRANSACTION"); err != nil {
+		return fmt.Errorf("failed to begin a transaction for validate(%q): %w", "duckdb", err)
+	}
+	for _, query := range s.applied {
+		if _, err := s.conn.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("failed to apply a validated statement again: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *validationSession) restart(ctx context.Context) error {
+	if _, err := s.conn.ExecContext(ctx, "ROLLBACK"); err != nil {
+		return fmt.Errorf("failed to roll back the transaction for validate(%q): %w", "duckdb", err)
+	}
+	return s.begin(ctx)
+}
+
+// validateStatement prepares the statement without running it, and reports whether it only changes the catalog so that
+// [Validate] can apply it for the statements that follow.
+func validateStatement(ctx context.Context, conn *sql.Conn, index int, query string) (ducktape.StatementValidation, bool) {
+	validation := ducktape.StatementValidation{Index: index}
+
+	var statementType duckdb.StmtType
+	err := conn.Raw(func(driverConn any) error {
+		// Prepare (unlike PrepareContext) refuses multiple statements instead of executing all but the last one.
+		driverStmt, err := driverConn.(*duckdb.Conn).Prepare(query)
+		if err != nil {
+			return err
+		}
+		defer driverStmt.Close()
+
+		stmt := driverStmt.(*duckdb.Stmt)
+		validation.ParameterCount = stmt.NumInput()
+		statementType, err = stmt.StatementType()
+		return err
+	})
+	if err != nil {
+		errMsg := err.Error()
+		validation.Error = &errMsg
+		return validation, false
+	}
+	validation.StatementType = statementTypeNames[statementType]
+
+	apply := false
+	switch statementType {
+	case duckdb.STATEMENT_TYPE_SELECT:
+		columns, err := describeColumns(ctx, conn, query, validation.ParameterCount)
+		if err != nil {
+			errMsg := err.Error()
+			validation.Error = &errMsg
+			return validation, false
+		}
+		validation.Columns = columns
+	case duckdb.STATEMENT_TYPE_CREATE, duckdb.STATEMENT_TYPE_DROP:
+		if validation.ParameterCount == 0 {
+			apply = isCatalogOnly(ctx, conn, query)
+		}
+	}
+
+	validation.Valid = true
+	return validation, apply
+}
+
+// isCatalogOnly reports whether the plan of the statement is a single operator from [catalogOperators], without any
+// query feeding it.
+func isCatalogOnly(ctx context.Context, conn *sql.Conn, query string) bool {
+	var key, plan string
+	if err := conn.QueryRowContext(ctx, "EXPLAIN (FORMAT json) "+query).Scan(&key, &plan); err != nil {
+		return false
+	}
+	var operators []struct {
+		Name     string `json:"name"`
+		Children []any  `json:"children"`
+	}
+	if err := json.Unmarshal([]byte(plan), &operators); err != nil {
+		return false
+	}
+	return len(operators) == 1 && len(operators[0].Children) == 0 && catalogOperators[operators[0].Name]
+}
+
+// describeColumns returns the result columns of a SELECT statement, binding every parameter to NULL.
+func describeColumns(ctx context.Context, conn *sql.Conn, query string, parameterCount int) ([]ducktape.ColumnDescription, error) {
+	rows, err := conn.QueryContext(ctx, "DESCRIBE "+query, make([]any, parameterCount)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe the result columns: %w", err)
+	}
+	defer rows.Close()
+
+	var columns []ducktape.ColumnDescription
+	for rows.Next() {
+		var column ducktape.ColumnDescription
+		var null, key, defaultValue, extra sql.NullString
+		if err := rows.Scan(&column.Name, &column.Type, &null, &key, &defaultValue, &extra); err != nil {
+			return nil, fmt.Errorf("failed to scan the result column: %w", err)
+		}
+		columns = append(columns, column)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over result columns: %w", err)
+	}
+	return columns, nil
+}
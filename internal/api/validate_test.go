@@ -0,0 +1,130 @@
+package api
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/artie-labs/ducktape/api/pkg/ducktape"
+	_ "github.com/duckdb/duckdb-go/v2"
+)
+
+func TestValidate(t *testing.T) {
+	ctx := context.Background()
+	dsn := "test_validate.db"
+	t.Cleanup(func() { os.Remove(dsn) })
+
+	_, err := Execute(ctx, dsn, ducktape.ExecuteRequest{
+		Statements: []ducktape.ExecuteStatement{
+			{Query: `CREATE TABLE test_validate (id INTEGER, amount DECIMAL(10,2), name VARCHAR)`},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	t.Run("select reports parameters and columns", func(t *testing.T) {
+		validation, err := Validate(ctx, dsn, []ducktape.ExecuteStatement{
+			{Query: "SELECT id, amount, name || '!' AS shout FROM test_validate WHERE id = ? AND name = ?"},
+		})
+		if err != nil {
+			t.Fatalf("failed to validate: %v", err)
+		}
+
+		if len(validation) != 1 {
+			t.Fatalf("expected 1 validation, got %d", len(validation))
+		}
+		if !validation[0].Valid {
+			t.Fatalf("expected statement to be valid, got error %v", *validation[0].Error)
+		}
+		if validation[0].StatementType != "SELECT" {
+			t.Errorf("expected statement type SELECT, got %q", validation[0].StatementType)
+		}
+		if validation[0].ParameterCount != 2 {
+			t.Errorf("expected 2 parameters, got %d", validation[0].ParameterCount)
+		}
+
+		expected := []ducktape.ColumnDescription{
+			{Name: "id", Type: "INTEGER"},
+			{Name: "amount", Type: "DECIMAL(10,2)"},
+			{Name: "shout", Type: "VARCHAR"},
+		}
+		if len(validation[0].Columns) != len(expected) {
+			t.Fatalf("expected %d columns, got %v", len(expected), validation[0].Columns)
+		}
+		for i, column := range expected {
+			if validation[0].Columns[i] != column {
+				t.Errorf("expected column %d to be %v, got %v", i, column, validation[0].Columns[i])
+			}
+		}
+	})
+
+	t.Run("migration binds against tables created earlier in the batch", func(t *testing.T) {
+		validation, err := Validate(ctx, dsn, []ducktape.ExecuteStatement{
+			{Query: "CREATE TABLE test_validate_new (id INTEGER)"},
+			{Query: "INSERT INTO test_validate_new VALUES (?)"},
+			{Query: "INSERT INTO missing_table VALUES (1)"},
+			{Query: "NOT EVEN SQL"},
+		})
+		if err != nil {
+			t.Fatalf("failed to validate: %v", err)
+		}
+
+		if !validation[0].Valid || validation[0].StatementType != "CREATE" {
+			t.Errorf("expected a valid CREATE statement, got %+v", validation[0])
+		}
+		if !validation[1].Valid || validation[1].ParameterCount != 1 {
+			t.Errorf("expected a valid INSERT with 1 parameter, got %+v", validation[1])
+		}
+		if validation[2].Valid || validation[2].Error == nil {
+			t.Errorf("expected insert into a missing table to be invalid, got %+v", validation[2])
+		}
+		if validation[3].Valid || validation[3].Error == nil {
+			t.Errorf("expected a parser error, got %+v", validation[3])
+		}
+
+		// Nothing was committed
+		rows, err := Query(ctx, dsn, ducktape.QueryRequest{
+			Query: "SELECT * FROM information_schema.tables WHERE table_name = 'test_validate_new'",
+		})
+		if err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+		if len(rows) != 0 {
+			t.Errorf("expected the dry run to leave no table behind, got %d", len(rows))
+		}
+	})
+
+	t.Run("failing statement does not invalidate the rest of the batch", func(t *testing.T) {
+		validation, err := Validate(ctx, dsn, []ducktape.ExecuteStatement{
+			{Query: "CREATE TABLE test_validate_other (id INTEGER)"},
+			{Query: "CREATE TABLE test_validate (id INTEGER)"},
+			{Query: "INSERT INTO test_validate_other VALUES (1)"},
+		})
+		if err != nil {
+			t.Fatalf("failed to validate: %v", err)
+		}
+		if validation[1].Valid {
+			t.Errorf("expected creating an existing table to be invalid, got %+v", validation[1])
+		}
+		if !validation[0].Valid || !validation[2].Valid {
+			t.Errorf("expected the other statements to stay valid, got %+v", validation)
+		}
+	})
+
+	t.Run("statements that process data are not run", func(t *testing.T) {
+		validation, err := Validate(ctx, dsn, []ducktape.ExecuteStatement{
+			{Query: "CREATE TABLE test_validate_copy AS SELECT * FROM test_validate"},
+			{Query: "SELECT * FROM test_validate_copy"},
+		})
+		if err != nil {
+			t.Fatalf("failed to validate: %v", err)
+		}
+		if !validation[0].Valid || validation[0].StatementType != "CREATE" {
+			t.Errorf("expected a valid CREATE statement, got %+v", validation[0])
+		}
+		if validation[1].Valid {
+			t.Errorf("expected the table created from a query not to exist, got %+v", validation[1])
+		}
+	})
+}
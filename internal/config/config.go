@@ -0,0 +1,301 @@
+// Package config loads the configuration of the server from a YAML or TOML file, the environment and command-line
+// flags.
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the configuration of the server. Every setting can be set in the config file, through its environment
+// variable (the env tag) or its command-line flag (the flag tag).
+type Config struct {
+	Listen   string         `yaml:"listen" toml:"listen" env:"DUCKTAPE_LISTEN" flag:"listen" help:"address to listen on"`
+	Server   ServerConfig   `yaml:"server" toml:"server"`
+	Log      LogConfig      `yaml:"log" toml:"log"`
+	Tracing  TracingConfig  `yaml:"tracing" toml:"tracing"`
+	Shutdown ShutdownConfig `yaml:"shutdown" toml:"shutdown"`
+	Health   HealthConfig   `yaml:"health" toml:"health"`
+	TLS      TLSConfig      `yaml:"tls" toml:"tls"`
+	Limits   LimitsConfig   `yaml:"limits" toml:"limits"`
+	Cache    CacheConfig    `yaml:"cache" toml:"cache"`
+	Jobs     JobsConfig     `yaml:"jobs" toml:"jobs"`
+	DSN      DSNConfig      `yaml:"dsn" toml:"dsn"`
+	Auth     AuthConfig     `yaml:"auth" toml:"auth"`
+	DuckDB   DuckDBConfig   `yaml:"duckdb" toml:"duckdb"`
+}
+
+type ServerConfig struct {
+	ReadHeaderTimeout Duration `yaml:"readHeaderTimeout" toml:"readHeaderTimeout" env:"DUCKTAPE_READ_HEADER_TIMEOUT" flag:"read-header-timeout" help:"how long a client may take to send the headers of a request, 0 for unlimited"`
+	ReadTimeout       Duration `yaml:"readTimeout" toml:"readTimeout" env:"DUCKTAPE_READ_TIMEOUT" flag:"read-timeout" help:"how long a client may take to send a whole request, 0 for unlimited"`
+	// WriteTimeout also bounds streamed responses and appends, so it is unlimited by default.
+	WriteTimeout   Duration `yaml:"writeTimeout" toml:"writeTimeout" env:"DUCKTAPE_WRITE_TIMEOUT" flag:"write-timeout" help:"how long the server may take to handle a request and write its response, 0 for unlimited"`
+	IdleTimeout    Duration `yaml:"idleTimeout" toml:"idleTimeout" env:"DUCKTAPE_IDLE_TIMEOUT" flag:"idle-timeout" help:"how long an idle keep-alive connection is kept open"`
+	MaxHeaderBytes int      `yaml:"maxHeaderBytes" toml:"maxHeaderBytes" env:"DUCKTAPE_MAX_HEADER_BYTES" flag:"max-header-bytes" help:"maximum size in bytes of the headers of a request"`
+	// HTTP2MaxConcurrentStreams caps the requests a single HTTP/2 connection may have in flight.
+	HTTP2MaxConcurrentStreams int      `yaml:"http2MaxConcurrentStreams" toml:"http2MaxConcurrentStreams" env:"DUCKTAPE_HTTP2_MAX_CONCURRENT_STREAMS" flag:"http2-max-concurrent-streams" help:"maximum number of concurrent requests on an HTTP/2 connection"`
+	HTTP2WriteByteTimeout     Duration `yaml:"http2WriteByteTimeout" toml:"http2WriteByteTimeout" env:"DUCKTAPE_HTTP2_WRITE_BYTE_TIMEOUT" flag:"http2-write-byte-timeout" help:"how long an HTTP/2 connection may go without accepting written data before it is closed, 0 for unlimited"`
+}
+
+type LogConfig struct {
+	Level string `yaml:"level" toml:"level" env:"DUCKTAPE_LOG" flag:"log-level" help:"log level: debug, info, warn or error"`
+}
+
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter" toml:"exporter" env:"DUCKTAPE_TRACING_EXPORTER" flag:"tracing-exporter" help:"where spans are exported: none, otlp or stdout"`
+	Endpoint    string  `yaml:"endpoint" toml:"endpoint" env:"DUCKTAPE_OTLP_ENDPOINT" flag:"otlp-endpoint" help:"OTLP/HTTP URL that spans are sent to, e.g. http://localhost:4318/v1/traces, defaults to the OTEL_EXPORTER_OTLP_* variables"`
+	ServiceName string  `yaml:"serviceName" toml:"serviceName" env:"DUCKTAPE_TRACING_SERVICE_NAME" flag:"tracing-service-name" help:"service name of the spans"`
+	SampleRatio float64 `yaml:"sampleRatio" toml:"sampleRatio" env:"DUCKTAPE_TRACING_SAMPLE_RATIO" flag:"tracing-sample-ratio" help:"share of the traces started by the server that are sampled, between 0 and 1"`
+}
+
+type ShutdownConfig struct {
+	// DrainDelay gives load balancers time to see the server as not ready before it stops accepting connections.
+	DrainDelay   Duration `yaml:"drainDelay" toml:"drainDelay" env:"DUCKTAPE_DRAIN_DELAY" flag:"drain-delay" help:"how long readiness fails on shutdown before the server stops accepting connections"`
+	DrainTimeout Duration `yaml:"drainTimeout" toml:"drainTimeout" env:"DUCKTAPE_DRAIN_TIMEOUT" flag:"drain-timeout" help:"how long in-flight requests and jobs may run on shutdown before they are cancelled"`
+}
+
+type HealthConfig struct {
+	// DSNs are checked on top of the DSN aliases.
+	DSNs         []string `yaml:"dsns" toml:"dsns" env:"DUCKTAPE_HEALTH_DSNS" flag:"health-dsns" help:"comma-separated databases that readiness opens and queries, on top of the DSN aliases"`
+	MinFreeBytes int64    `yaml:"minFreeBytes" toml:"minFreeBytes" env:"DUCKTAPE_HEALTH_MIN_FREE_BYTES" flag:"health-min-free-bytes" help:"free space in bytes the data directory needs for the server to be ready, 0 to disable"`
+	CheckTimeout Duration `yaml:"checkTimeout" toml:"checkTimeout" env:"DUCKTAPE_HEALTH_CHECK_TIMEOUT" flag:"health-check-timeout" help:"how long the checks of a readiness probe may take"`
+}
+
+type TLSConfig struct {
+	CertFile          string   `yaml:"certFile" toml:"certFile" env:"DUCKTAPE_TLS_CERT_FILE" flag:"tls-cert-file" help:"PEM certificate to serve TLS with"`
+	KeyFile           string   `yaml:"keyFile" toml:"keyFile" env:"DUCKTAPE_TLS_KEY_FILE" flag:"tls-key-file" help:"PEM private key of the certificate"`
+	ClientCAFile      string   `yaml:"clientCAFile" toml:"clientCAFile" env:"DUCKTAPE_TLS_CLIENT_CA_FILE" flag:"tls-client-ca-file" help:"PEM CAs that client certificates are verified against"`
+	RequireClientCert bool     `yaml:"requireClientCert" toml:"requireClientCert" env:"DUCKTAPE_TLS_REQUIRE_CLIENT_CERT" flag:"tls-require-client-cert" help:"reject connections without a valid client certificate"`
+	ReloadInterval    Duration `yaml:"reloadInterval" toml:"reloadInterval" env:"DUCKTAPE_TLS_RELOAD_INTERVAL" flag:"tls-reload-interval" help:"how often the certificate files are checked for changes"`
+}
+
+type LimitsConfig struct {
+	MaxQueryDuration Duration `yaml:"maxQueryDuration" toml:"maxQueryDuration" env:"DUCKTAPE_MAX_QUERY_DURATION" flag:"max-query-duration" help:"maximum duration of a query, execute, append or explain request, 0 for unlimited"`
+	MaxResultRows    int      `yaml:"maxResultRows" toml:"maxResultRows" env:"DUCKTAPE_MAX_RESULT_ROWS" flag:"max-result-rows" help:"maximum number of rows in a query response, 0 for unlimited"`
+	MaxResultBytes   int64    `yaml:"maxResultBytes" toml:"maxResultBytes" env:"DUCKTAPE_MAX_RESULT_BYTES" flag:"max-result-bytes" help:"maximum size in bytes of the rows in a query response, 0 for unlimited"`
+	MaxBodyBytes     int64    `yaml:"maxBodyBytes" toml:"maxBodyBytes" env:"DUCKTAPE_MAX_BODY_BYTES" flag:"max-body-bytes" help:"maximum size in bytes of a request body other than an append, 0 for unlimited"`
+	// RouteMaxBodyBytes overrides MaxBodyBytes for some routes, keyed by their path.
+	RouteMaxBodyBytes map[string]int64 `yaml:"routeMaxBodyBytes" toml:"routeMaxBodyBytes" env:"DUCKTAPE_ROUTE_MAX_BODY_BYTES" flag:"route-max-body-bytes" help:"comma-separated path=bytes request body limits of single routes, 0 for unlimited"`
+}
+
+type CacheConfig struct {
+	StatementCacheSize int   `yaml:"statementCacheSize" toml:"statementCacheSize" env:"DUCKTAPE_STATEMENT_CACHE_SIZE" flag:"statement-cache-size" help:"number of prepared statements cached for queries, 0 to disable"`
+	ResultCacheBytes   int64 `yaml:"resultCacheBytes" toml:"resultCacheBytes" env:"DUCKTAPE_RESULT_CACHE_BYTES" flag:"result-cache-bytes" help:"memory budget in bytes of the query result cache, 0 to disable"`
+}
+
+type JobsConfig struct {
+	Dir         string   `yaml:"dir" toml:"dir" env:"DUCKTAPE_JOB_DIR" flag:"job-dir" help:"directory where async job results are stored"`
+	ResultTTL   Duration `yaml:"resultTTL" toml:"resultTTL" env:"DUCKTAPE_JOB_RESULT_TTL" flag:"job-result-ttl" help:"how long the results of a finished job are kept"`
+	MaxDuration Duration `yaml:"maxDuration" toml:"maxDuration" env:"DUCKTAPE_MAX_JOB_DURATION" flag:"max-job-duration" help:"maximum duration of an async job, 0 for unlimited"`
+}
+
+type DSNConfig struct {
+	DataDir       string `yaml:"dataDir" toml:"dataDir" env:"DUCKTAPE_DATA_DIR" flag:"data-dir" help:"directory that file databases and SQL file access are confined to"`
+	AllowInMemory bool   `yaml:"allowInMemory" toml:"allowInMemory" env:"DUCKTAPE_ALLOW_IN_MEMORY" flag:"allow-in-memory" help:"allow in-memory databases while the data directory is set"`
+	// AllowedOptions is nil when every option is allowed, and empty when none is.
+	AllowedOptions *[]string         `yaml:"allowedOptions" toml:"allowedOptions" env:"DUCKTAPE_ALLOWED_DSN_OPTIONS" flag:"allowed-dsn-options" help:"comma-separated DSN options clients may set"`
+	DeniedOptions  []string          `yaml:"deniedOptions" toml:"deniedOptions" env:"DUCKTAPE_DENIED_DSN_OPTIONS" flag:"denied-dsn-options" help:"comma-separated DSN options clients may never set"`
+	Aliases        map[string]string `yaml:"aliases" toml:"aliases" env:"DUCKTAPE_DSN_ALIASES" flag:"dsn-aliases" help:"comma-separated name=dsn pairs clients can use as their DSN"`
+	ReadOnly       bool              `yaml:"readOnly" toml:"readOnly" env:"DUCKTAPE_READ_ONLY" flag:"read-only" help:"reject anything but SELECT statements on every request"`
+	ReadOnlyDSNs   []string          `yaml:"readOnlyDSNs" toml:"readOnlyDSNs" env:"DUCKTAPE_READ_ONLY_DSNS" flag:"read-only-dsns" help:"comma-separated databases that only accept SELECT statements"`
+}
+
+type AuthConfig struct {
+	// APIKeys maps the name of each API key to the key.
+	APIKeys map[string]string `yaml:"apiKeys" toml:"apiKeys" env:"DUCKTAPE_API_KEYS" flag:"api-keys" help:"comma-separated name=key API keys"`
+	// JWTKeys maps key IDs to base64-encoded HMAC secrets.
+	JWTKeys     map[string]string `yaml:"jwtKeys" toml:"jwtKeys" env:"DUCKTAPE_JWT_KEYS" flag:"jwt-keys" help:"comma-separated kid=secret HMAC keys, base64-encoded"`
+	JWTIssuer   string            `yaml:"jwtIssuer" toml:"jwtIssuer" env:"DUCKTAPE_JWT_ISSUER" flag:"jwt-issuer" help:"required iss claim of JWTs"`
+	JWTAudience string            `yaml:"jwtAudience" toml:"jwtAudience" env:"DUCKTAPE_JWT_AUDIENCE" flag:"jwt-audience" help:"required aud claim of JWTs"`
+	PolicyFile  string            `yaml:"policyFile" toml:"policyFile" env:"DUCKTAPE_POLICY_FILE" flag:"policy-file" help:"YAML or JSON file of per-principal authorization policies"`
+}
+
+type DuckDBConfig struct {
+	// Defaults are DuckDB options added to every DSN that does not set them.
+	Defaults map[string]string `yaml:"defaults" toml:"defaults" env:"DUCKTAPE_DUCKDB_DEFAULTS" flag:"duckdb-defaults" help:"comma-separated name=value DuckDB options added to every DSN that does not set them"`
+}
+
+// Default returns the configuration used for the settings that are not set anywhere.
+func Default() Config {
+	return Config{
+		Listen: "0.0.0.0:8080",
+		Server: ServerConfig{
+			ReadHeaderTimeout:         Duration(10 * time.Second),
+			IdleTimeout:               Duration(2 * time.Minute),
+			MaxHeaderBytes:            1 << 20,
+			HTTP2MaxConcurrentStreams: 250,
+		},
+		Log:      LogConfig{Level: "info"},
+		Tracing:  TracingConfig{Exporter: "none", ServiceName: "ducktape", SampleRatio: 1},
+		Shutdown: ShutdownConfig{DrainTimeout: Duration(30 * time.Second)},
+		Health:   HealthConfig{CheckTimeout: Duration(5 * time.Second)},
+		TLS:      TLSConfig{ReloadInterval: Duration(30 * time.Second)},
+		Limits:   LimitsConfig{MaxBodyBytes: 16 << 20},
+		Jobs:     JobsConfig{ResultTTL: Duration(time.Hour)},
+	}
+}
+
+// Duration is a [time.Duration] that is written as a string such as "5m" in config files, environment variables and
+// flags.
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// LogLevel returns the [slog.Level] of Log.Level, which also accepts the first letter of each level.
+func (c Config) LogLevel() (slog.Level, error) {
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "d":
+		return slog.LevelDebug, nil
+	case "info", "i", "":
+		return slog.LevelInfo, nil
+	case "warn", "w", "warning":
+		return slog.LevelWarn, nil
+	case "error", "e":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("unknown log level %q", c.Log.Level)
+	}
+}
+
+// Validate checks the configuration and returns every problem it finds.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	if _, port, err := net.SplitHostPort(c.Listen); err != nil {
+		errs = append(errs, fmt.Errorf("listen: %w", err))
+	} else if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		errs = append(errs, fmt.Errorf("listen: invalid port %q", port))
+	}
+	if _, err := c.LogLevel(); err != nil {
+		errs = append(errs, fmt.Errorf("log.level: %w", err))
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "otlp", "stdout":
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter: unknown exporter %q, expected none, otlp or stdout", c.Tracing.Exporter))
+	}
+	if c.Tracing.Endpoint != "" {
+		if u, err := url.Parse(c.Tracing.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("tracing.endpoint: %q is not an http:// or https:// URL", c.Tracing.Endpoint))
+		}
+	}
+	check(c.Tracing.ServiceName != "", "tracing.serviceName: must not be empty")
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sampleRatio: must be between 0 and 1")
+	check(c.Server.ReadHeaderTimeout >= 0, "server.readHeaderTimeout: must not be negative")
+	check(c.Server.ReadTimeout >= 0, "server.readTimeout: must not be negative")
+	check(c.Server.WriteTimeout >= 0, "server.writeTimeout: must not be negative")
+	check(c.Server.IdleTimeout >= 0, "server.idleTimeout: must not be negative")
+	check(c.Server.MaxHeaderBytes > 0, "server.maxHeaderBytes: must be positive")
+	check(c.Server.HTTP2MaxConcurrentStreams > 0, "server.http2MaxConcurrentStreams: must be positive")
+	check(c.Server.HTTP2WriteByteTimeout >= 0, "server.http2WriteByteTimeout: must not be negative")
+	check(c.Shutdown.DrainDelay >= 0, "shutdown.drainDelay: must not be negative")
+	check(c.Shutdown.DrainTimeout >= 0, "shutdown.drainTimeout: must not be negative")
+	check(c.Health.MinFreeBytes >= 0, "health.minFreeBytes: must not be negative")
+	check(c.Health.CheckTimeout > 0, "health.checkTimeout: must be positive")
+	check((c.TLS.CertFile == "") == (c.TLS.KeyFile == ""), "tls: certFile and keyFile must be set together")
+	check(c.TLS.ClientCAFile == "" || c.TLS.CertFile != "", "tls.clientCAFile: requires certFile and keyFile")
+	check(!c.TLS.RequireClientCert || c.TLS.ClientCAFile != "", "tls.requireClientCert: requires clientCAFile")
+	check(c.TLS.CertFile == "" || c.TLS.ReloadInterval > 0, "tls.reloadInterval: must be positive")
+	for name, path := range map[string]string{
+		"tls.certFile":     c.TLS.CertFile,
+		"tls.keyFile":      c.TLS.KeyFile,
+		"tls.clientCAFile": c.TLS.ClientCAFile,
+		"auth.policyFile":  c.Auth.PolicyFile,
+	} {
+		if path != "" {
+			if _, err := os.Stat(path); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+		}
+	}
+
+	check(c.Limits.MaxQueryDuration >= 0, "limits.maxQueryDuration: must not be negative")
+	check(c.Limits.MaxResultRows >= 0, "limits.maxResultRows: must not be negative")
+	check(c.Limits.MaxResultBytes >= 0, "limits.maxResultBytes: must not be negative")
+	check(c.Limits.MaxBodyBytes >= 0, "limits.maxBodyBytes: must not be negative")
+	for path, limit := range c.Limits.RouteMaxBodyBytes {
+		check(strings.HasPrefix(path, "/"), "limits.routeMaxBodyBytes: %q is not a route path such as /api/append", path)
+		check(limit >= 0, "limits.routeMaxBodyBytes: the limit of %q must not be negative", path)
+	}
+	check(c.Cache.StatementCacheSize >= 0, "cache.statementCacheSize: must not be negative")
+	check(c.Cache.ResultCacheBytes >= 0, "cache.resultCacheBytes: must not be negative")
+	check(c.Jobs.ResultTTL > 0, "jobs.resultTTL: must be positive")
+	check(c.Jobs.MaxDuration >= 0, "jobs.maxDuration: must not be negative")
+
+	if c.DSN.DataDir != "" {
+		if info, err := os.Stat(c.DSN.DataDir); err != nil {
+			errs = append(errs, fmt.Errorf("dsn.dataDir: %w", err))
+		} else {
+			check(info.IsDir(), "dsn.dataDir: %q is not a directory", c.DSN.DataDir)
+		}
+	}
+	for name, dsn := range c.DSN.Aliases {
+		check(name != "" && dsn != "", "dsn.aliases: %q=%q is not a valid alias", name, dsn)
+		check(!strings.Contains(name, "?"), "dsn.aliases: alias %q cannot contain options", name)
+	}
+
+	for name, key := range c.Auth.APIKeys {
+		check(name != "" && key != "", "auth.apiKeys: API key %q is empty", name)
+	}
+	for keyID, secret := range c.Auth.JWTKeys {
+		if _, err := base64.StdEncoding.DecodeString(secret); err != nil || secret == "" {
+			errs = append(errs, fmt.Errorf("auth.jwtKeys: the secret of key %q is not base64", keyID))
+		}
+	}
+	for name, value := range c.DuckDB.Defaults {
+		check(name != "" && value != "", "duckdb.defaults: %q=%q is not a valid option", name, value)
+	}
+	return errors.Join(errs...)
+}
+
+// Redacted returns a copy of the configuration without its secrets, so it can be printed.
+func (c Config) Redacted() Config {
+	redact := func(secrets map[string]string) map[string]string {
+		if secrets == nil {
+			return nil
+		}
+		redacted := make(map[string]string, len(secrets))
+		for name := range secrets {
+			redacted[name] = "REDACTED"
+		}
+		return redacted
+	}
+	c.Auth.APIKeys = redact(c.Auth.APIKeys)
+	c.Auth.JWTKeys = redact(c.Auth.JWTKeys)
+	return c
+}
+
+// isTOML tells whether a config file is in TOML rather than YAML, based on its extension.
+func isTOML(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".toml")
+}
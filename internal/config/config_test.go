@@ -0,0 +1,213 @@
+package config
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func env(values map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := values[name]
+		return value, ok
+	}
+}
+
+func writeConfig(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		loaded, err := Load(nil, env(nil), io.Discard)
+		if err != nil {
+			t.Fatalf("failed to load: %v", err)
+		}
+		if loaded.Config.Listen != "0.0.0.0:8080" || loaded.Config.Jobs.ResultTTL != Duration(time.Hour) {
+			t.Errorf("unexpected defaults: %+v", loaded.Config)
+		}
+		if loaded.Config.DSN.AllowedOptions != nil {
+			t.Error("expected every DSN option to be allowed by default")
+		}
+	})
+
+	t.Run("precedence", func(t *testing.T) {
+		path := writeConfig(t, "ducktape.yaml", `
+listen: 127.0.0.1:7000
+limits:
+  maxQueryDuration: 5m
+  maxResultRows: 100
+  maxResultBytes: 1000
+dsn:
+  aliases:
+    analytics: analytics.db
+`)
+		loaded, err := Load(
+			[]string{"--config", path, "--max-result-rows=300", "--read-only"},
+			env(map[string]string{"DUCKTAPE_MAX_RESULT_ROWS": "200", "DUCKTAPE_MAX_RESULT_BYTES": "2000", "DUCKTAPE_MAX_QUERY_DURATION": ""}),
+			io.Discard,
+		)
+		if err != nil {
+			t.Fatalf("failed to load: %v", err)
+		}
+		c := loaded.Config
+		if c.Listen != "127.0.0.1:7000" || c.Limits.MaxQueryDuration != Duration(5*time.Minute) {
+			t.Errorf("expected the file settings to apply, got %+v", c)
+		}
+		if c.Limits.MaxResultBytes != 2000 {
+			t.Errorf("expected the environment to override the file, got %d", c.Limits.MaxResultBytes)
+		}
+		if c.Limits.MaxResultRows != 300 || !c.DSN.ReadOnly {
+			t.Errorf("expected the flags to override the environment, got %+v", c.Limits)
+		}
+		if c.DSN.Aliases["analytics"] != "analytics.db" {
+			t.Errorf("unexpected aliases: %v", c.DSN.Aliases)
+		}
+	})
+
+	t.Run("toml", func(t *testing.T) {
+		path := writeConfig(t, "ducktape.toml", `
+listen = "127.0.0.1:7000"
+
+[jobs]
+resultTTL = "10m"
+
+[duckdb.defaults]
+threads = "4"
+`)
+		loaded, err := Load(nil, env(map[string]string{"DUCKTAPE_CONFIG": path}), io.Discard)
+		if err != nil {
+			t.Fatalf("failed to load: %v", err)
+		}
+		if loaded.File != path || loaded.Config.Jobs.ResultTTL != Duration(10*time.Minute) ||
+			loaded.Config.DuckDB.Defaults["threads"] != "4" {
+			t.Errorf("unexpected config: %+v", loaded.Config)
+		}
+	})
+
+	t.Run("environment", func(t *testing.T) {
+		loaded, err := Load(nil, env(map[string]string{
+			"PORT":                          "9000",
+			"DUCKTAPE_ALLOWED_DSN_OPTIONS":  "",
+			"DUCKTAPE_API_KEYS":             "etl=key-1, dashboards=key-2",
+			"DUCKTAPE_READ_ONLY_DSNS":       "a.db,b.db",
+			"DUCKTAPE_ROUTE_MAX_BODY_BYTES": "/api/append=0,/api/query=1024",
+			"DUCKTAPE_TRACING_SAMPLE_RATIO": "0.25",
+			"DUCKTAPE_HEALTH_DSNS":          "a.db,b.db?threads=1",
+		}), io.Discard)
+		if err != nil {
+			t.Fatalf("failed to load: %v", err)
+		}
+		c := loaded.Config
+		if c.Listen != "0.0.0.0:9000" {
+			t.Errorf("expected PORT to set the port, got %q", c.Listen)
+		}
+		if c.DSN.AllowedOptions == nil || len(*c.DSN.AllowedOptions) != 0 {
+			t.Errorf("expected an empty list of allowed DSN options, got %v", c.DSN.AllowedOptions)
+		}
+		if len(c.Auth.APIKeys) != 2 || c.Auth.APIKeys["dashboards"] != "key-2" || len(c.DSN.ReadOnlyDSNs) != 2 {
+			t.Errorf("unexpected config: %+v", c)
+		}
+		if limits := c.Limits.RouteMaxBodyBytes; len(limits) != 2 || limits["/api/append"] != 0 || limits["/api/query"] != 1024 {
+			t.Errorf("unexpected route body limits: %v", limits)
+		}
+		if len(c.Health.DSNs) != 2 || c.Health.DSNs[1] != "b.db?threads=1" {
+			t.Errorf("unexpected health check DSNs: %v", c.Health.DSNs)
+		}
+		if c.Tracing.SampleRatio != 0.25 {
+			t.Errorf("unexpected sample ratio: %v", c.Tracing.SampleRatio)
+		}
+	})
+
+	for name, tc := range map[string]struct {
+		args     []string
+		env      map[string]string
+		file     string
+		expected string
+	}{
+		"unknown flag":        {args: []string{"--nope"}, expected: "not defined"},
+		"invalid flag":        {args: []string{"--max-result-rows", "many"}, expected: "not an integer"},
+		"invalid environment": {env: map[string]string{"DUCKTAPE_DSN_ALIASES": "analytics"}, expected: "DUCKTAPE_DSN_ALIASES"},
+		"unknown setting":     {file: "limits:\n  maxRows: 1\n", expected: "maxRows"},
+		"invalid setting":     {file: "limits:\n  maxQueryDuration: soon\n", expected: "soon"},
+		"invalid values": {
+			args:     []string{"--listen", "8080", "--max-result-bytes=-1", "--tls-cert-file", "cert.pem"},
+			expected: "listen",
+		},
+		"missing file":     {args: []string{"--config", "missing.yaml"}, expected: "failed to read"},
+		"invalid jwt key":  {env: map[string]string{"DUCKTAPE_JWT_KEYS": "v1=not base64!"}, expected: "auth.jwtKeys"},
+		"missing data dir": {args: []string{"--data-dir", "/nonexistent/ducktape"}, expected: "dsn.dataDir"},
+		"invalid route limit": {
+			env:      map[string]string{"DUCKTAPE_ROUTE_MAX_BODY_BYTES": "/api/append=lots"},
+			expected: "/api/append",
+		},
+		"invalid server timeout": {args: []string{"--read-header-timeout=-1s"}, expected: "server.readHeaderTimeout"},
+		"invalid sample ratio":   {args: []string{"--tracing-sample-ratio", "half"}, expected: "half"},
+		"unknown exporter":       {env: map[string]string{"DUCKTAPE_TRACING_EXPORTER": "jaeger"}, expected: "tracing.exporter"},
+		"invalid otlp endpoint":  {args: []string{"--otlp-endpoint", "collector:4318"}, expected: "tracing.endpoint"},
+		"invalid health timeout": {args: []string{"--health-check-timeout=0s"}, expected: "health.checkTimeout"},
+	} {
+		args := tc.args
+		if tc.file != "" {
+			args = append(args, "--config", writeConfig(t, "ducktape.yaml", tc.file))
+		}
+		_, err := Load(args, env(tc.env), io.Discard)
+		if err == nil || !strings.Contains(err.Error(), tc.expected) {
+			t.Errorf("%s: expected an error mentioning %q, got %v", name, tc.expected, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	c := Default()
+	c.Listen = "localhost"
+	c.Limits.MaxResultBytes = -1
+	c.TLS.CertFile = "cert.pem"
+	c.TLS.RequireClientCert = true
+	err := c.Validate()
+	if err == nil {
+		t.Fatal("expected the config to be invalid")
+	}
+	// Every problem is reported at once
+	for _, expected := range []string{"listen", "limits.maxResultBytes", "certFile and keyFile", "requireClientCert"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q to be reported, got %v", expected, err)
+		}
+	}
+}
+
+func TestPrint(t *testing.T) {
+	c := Default()
+	c.Auth.APIKeys = map[string]string{"etl": "secret-key"}
+	c.Auth.JWTKeys = map[string]string{"v1": "c2VjcmV0"}
+	var buf bytes.Buffer
+	if err := Print(&buf, c); err != nil {
+		t.Fatalf("failed to print: %v", err)
+	}
+	printed := buf.String()
+	if strings.Contains(printed, "secret-key") || strings.Contains(printed, "c2VjcmV0") {
+		t.Errorf("expected secrets to be redacted, got:\n%s", printed)
+	}
+	if c.Auth.APIKeys["etl"] != "secret-key" {
+		t.Error("expected the config itself not to be redacted")
+	}
+
+	// The printed config can be loaded back
+	path := writeConfig(t, "printed.yaml", printed)
+	loaded, err := Load([]string{"--config", path}, env(nil), io.Discard)
+	if err != nil {
+		t.Fatalf("failed to load the printed config: %v", err)
+	}
+	if loaded.Config.TLS.ReloadInterval != c.TLS.ReloadInterval || loaded.Config.Auth.APIKeys["etl"] != "REDACTED" {
+		t.Errorf("unexpected config: %+v", loaded.Config)
+	}
+}
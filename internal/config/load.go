@@ -0,0 +1,284 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrHelp is returned by [Load] when the flags ask for the usage, which has already been printed.
+var ErrHelp = flag.ErrHelp
+
+// Loaded is the outcome of [Load].
+type Loaded struct {
+	Config Config
+	// File is the config file that was read, if any.
+	File string
+	// PrintConfig is set by the --print-config flag, the server should print the configuration and exit.
+	PrintConfig bool
+}
+
+// Load builds the configuration from, in increasing order of precedence: [Default], the config file (--config or
+// DUCKTAPE_CONFIG), the environment and the command-line flags. The result is validated.
+func Load(args []string, lookupEnv func(string) (string, bool), output io.Writer) (Loaded, error) {
+	var loaded Loaded
+	flags := flag.NewFlagSet("ducktape", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.StringVar(&loaded.File, "config", "", "YAML or TOML config file (env DUCKTAPE_CONFIG)")
+	flags.BoolVar(&loaded.PrintConfig, "print-config", false, "print the effective configuration and exit")
+
+	// Flags are parsed into their own copy of the configuration, only the ones that are set are then copied over
+	var fromFlags Config
+	fields := leaves(reflect.ValueOf(&fromFlags).Elem(), nil)
+	for _, field := range fields {
+		flags.Var(fieldValue{field.value}, field.flag, fmt.Sprintf("%s (env %s)", field.help, field.env))
+	}
+	if err := flags.Parse(args); err != nil {
+		return Loaded{}, err
+	}
+	if flags.NArg() > 0 {
+		return Loaded{}, fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+
+	loaded.Config = Default()
+	if loaded.File == "" {
+		loaded.File, _ = lookupEnv("DUCKTAPE_CONFIG")
+	}
+	if loaded.File != "" {
+		if err := decodeFile(loaded.File, &loaded.Config); err != nil {
+			return Loaded{}, err
+		}
+	}
+
+	config := reflect.ValueOf(&loaded.Config).Elem()
+	if port, ok := lookupEnv("PORT"); ok && port != "" {
+		// PORT predates DUCKTAPE_LISTEN and only sets the port
+		loaded.Config.Listen = "0.0.0.0:" + port
+	}
+	for _, field := range fields {
+		value, ok := lookupEnv(field.env)
+		// Empty variables are ignored, except for lists that tell apart being empty from not being set
+		if !ok || (value == "" && field.value.Kind() != reflect.Pointer) {
+			continue
+		}
+		if err := setValue(config.FieldByIndex(field.index), value); err != nil {
+			return Loaded{}, fmt.Errorf("failed to parse %s: %w", field.env, err)
+		}
+	}
+
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, field := range fields {
+		if set[field.flag] {
+			config.FieldByIndex(field.index).Set(field.value)
+		}
+	}
+
+	if err := loaded.Config.Validate(); err != nil {
+		return Loaded{}, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return loaded, nil
+}
+
+func decodeFile(path string, config *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read the config file: %w", err)
+	}
+	if isTOML(path) {
+		metadata, err := toml.Decode(string(data), config)
+		if err != nil {
+			return fmt.Errorf("failed to parse the config file: %w", err)
+		}
+		if undecoded := metadata.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("failed to parse the config file: unknown setting %q", undecoded[0].String())
+		}
+		return nil
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse the config file: %w", err)
+	}
+	return nil
+}
+
+// Print writes the configuration as YAML, without its secrets.
+func Print(w io.Writer, config Config) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(config.Redacted()); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// leaf is a setting of [Config] that can be set through the environment and flags.
+type leaf struct {
+	index []int
+	value reflect.Value
+	env   string
+	flag  string
+	help  string
+}
+
+func leaves(v reflect.Value, index []int) []leaf {
+	var result []leaf
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		fieldIndex := append(append([]int{}, index...), i)
+		if field.Type.Kind() == reflect.Struct && field.Tag.Get("env") == "" {
+			result = append(result, leaves(v.Field(i), fieldIndex)...)
+			continue
+		}
+		result = append(result, leaf{
+			index: fieldIndex,
+			value: v.Field(i),
+			env:   field.Tag.Get("env"),
+			flag:  field.Tag.Get("flag"),
+			help:  field.Tag.Get("help"),
+		})
+	}
+	return result
+}
+
+var durationType = reflect.TypeFor[Duration]()
+
+// setValue parses a setting from an environment variable or a flag: lists are comma-separated and maps are
+// comma-separated name=value pairs.
+func setValue(v reflect.Value, raw string) error {
+	if v.Type() == durationType {
+		var d Duration
+		if err := d.UnmarshalText([]byte(raw)); err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(parsed)
+	case reflect.Int, reflect.Int64:
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", raw)
+		}
+		v.SetInt(parsed)
+	case reflect.Float64:
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		v.SetFloat(parsed)
+	case reflect.Slice:
+		v.Set(reflect.ValueOf(splitList(raw)))
+	case reflect.Pointer:
+		list := append([]string{}, splitList(raw)...)
+		v.Set(reflect.ValueOf(&list))
+	case reflect.Map:
+		pairs, err := parsePairs(raw)
+		if err != nil {
+			return err
+		}
+		if pairs == nil {
+			v.SetZero()
+			return nil
+		}
+		m := reflect.MakeMapWithSize(v.Type(), len(pairs))
+		for name, value := range pairs {
+			element := reflect.New(v.Type().Elem()).Elem()
+			if err := setValue(element, value); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			m.SetMapIndex(reflect.ValueOf(name), element)
+		}
+		v.Set(m)
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+func formatValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Slice:
+		return strings.Join(v.Interface().([]string), ",")
+	case reflect.Pointer:
+		if v.IsNil() {
+			return ""
+		}
+		return formatValue(v.Elem())
+	case reflect.Map:
+		pairs := make([]string, 0, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			pairs = append(pairs, iter.Key().String()+"="+formatValue(iter.Value()))
+		}
+		return strings.Join(pairs, ",")
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+// fieldValue is the [flag.Value] of a setting.
+type fieldValue struct {
+	v reflect.Value
+}
+
+func (f fieldValue) String() string {
+	if !f.v.IsValid() || f.v.IsZero() {
+		return ""
+	}
+	return formatValue(f.v)
+}
+
+func (f fieldValue) Set(raw string) error {
+	return setValue(f.v, raw)
+}
+
+func (f fieldValue) IsBoolFlag() bool {
+	return f.v.IsValid() && f.v.Kind() == reflect.Bool
+}
+
+// splitList splits a comma-separated list, ignoring blank entries.
+func splitList(value string) []string {
+	var values []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			values = append(values, entry)
+		}
+	}
+	return values
+}
+
+// parsePairs parses comma-separated name=value pairs.
+func parsePairs(value string) (map[string]string, error) {
+	var pairs map[string]string
+	for _, pair := range splitList(value) {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" || value == "" {
+			return nil, fmt.Errorf("%q is not of the form name=value", pair)
+		}
+		if pairs == nil {
+			pairs = make(map[string]string)
+		}
+		pairs[key] = value
+	}
+	return pairs, nil
+}
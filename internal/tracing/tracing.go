@@ -0,0 +1,79 @@
+// Package tracing exports the spans of the server through OpenTelemetry.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	// ExporterNone only propagates the trace context, e.g. to the request logs, without exporting spans.
+	ExporterNone = "none"
+	// ExporterOTLP exports spans to an OTLP/HTTP endpoint.
+	ExporterOTLP = "otlp"
+	// ExporterStdout writes spans as JSON, for debugging.
+	ExporterStdout = "stdout"
+)
+
+// Options configures [Setup].
+type Options struct {
+	// Exporter is one of ExporterNone, ExporterOTLP and ExporterStdout.
+	Exporter string
+	// Endpoint is the URL that OTLP spans are sent to, e.g. "http://localhost:4318/v1/traces". Empty falls back to the
+	// standard OTEL_EXPORTER_OTLP_* environment variables.
+	Endpoint string
+	// ServiceName is the service.name of the spans.
+	ServiceName string
+	// SampleRatio is the share of the traces started by the server that are sampled, the traces started by clients
+	// follow their sampling decision.
+	SampleRatio float64
+	// Output is where ExporterStdout writes.
+	Output io.Writer
+}
+
+// Setup installs the W3C trace context and baggage propagators and, unless the exporter is [ExporterNone], a global
+// tracer provider. The returned function flushes the spans that have not been exported yet and stops the exporter.
+func Setup(ctx context.Context, o Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch o.Exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		var opts []otlptracehttp.Option
+		if o.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(o.Endpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(o.Output))
+	default:
+		return nil, fmt.Errorf("unknown span exporter %q", o.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the %s span exporter: %w", o.Exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", o.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe the service: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(o.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
@@ -8,39 +8,23 @@ import (
 	"time"
 )
 
-func RowsToObjects(rows *sql.Rows) ([]map[string]any, error) {
-	defer rows.Close()
-
-	columns, err := rows.Columns()
-	if err != nil {
-		return nil, err
+// ScanObject scans the current row into a map keyed by column name
+func ScanObject(rows *sql.Rows, columns []string) (map[string]any, error) {
+	row := make([]any, len(columns))
+	rowPointers := make([]any, len(columns))
+	for i := range row {
+		rowPointers[i] = &row[i]
 	}
 
-	var objects []map[string]any
-	for rows.Next() {
-		row := make([]any, len(columns))
-		rowPointers := make([]any, len(columns))
-		for i := range row {
-			rowPointers[i] = &row[i]
-		}
-
-		if err = rows.Scan(rowPointers...); err != nil {
-			return nil, err
-		}
-
-		object := make(map[string]any)
-		for i, column := range columns {
-			object[column] = row[i]
-		}
-
-		objects = append(objects, object)
+	if err := rows.Scan(rowPointers...); err != nil {
+		return nil, err
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("failed to iterate over rows: %w", err)
+	object := make(map[string]any)
+	for i, column := range columns {
+		object[column] = row[i]
 	}
-
-	return objects, nil
+	return object, nil
 }
 
 type ColumnMetadata struct {
@@ -10,7 +10,26 @@ import (
 	_ "github.com/duckdb/duckdb-go/v2"
 )
 
-func TestRowsToObjects(t *testing.T) {
+// scanObjects scans every row with ScanObject, like readPage does without its limits.
+func scanObjects(rows *sql.Rows) ([]map[string]any, error) {
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var objects []map[string]any
+	for rows.Next() {
+		object, err := ScanObject(rows, columns)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, object)
+	}
+	return objects, rows.Err()
+}
+
+func TestScanObject(t *testing.T) {
 	db, err := sql.Open("duckdb", "")
 	if err != nil {
 		t.Fatalf("failed to open database: %v", err)
@@ -47,9 +66,9 @@ func TestRowsToObjects(t *testing.T) {
 			t.Fatalf("failed to query: %v", err)
 		}
 
-		objects, err := RowsToObjects(rows)
+		objects, err := scanObjects(rows)
 		if err != nil {
-			t.Fatalf("RowsToObjects failed: %v", err)
+			t.Fatalf("failed to scan the rows: %v", err)
 		}
 
 		if len(objects) != 3 {
@@ -86,9 +105,9 @@ func TestRowsToObjects(t *testing.T) {
 			t.Fatalf("failed to query: %v", err)
 		}
 
-		objects, err := RowsToObjects(rows)
+		objects, err := scanObjects(rows)
 		if err != nil {
-			t.Fatalf("RowsToObjects failed: %v", err)
+			t.Fatalf("failed to scan the rows: %v", err)
 		}
 
 		if len(objects) != 0 {
@@ -113,9 +132,9 @@ func TestRowsToObjects(t *testing.T) {
 			t.Fatalf("failed to query: %v", err)
 		}
 
-		objects, err := RowsToObjects(rows)
+		objects, err := scanObjects(rows)
 		if err != nil {
-			t.Fatalf("RowsToObjects failed: %v", err)
+			t.Fatalf("failed to scan the rows: %v", err)
 		}
 
 		if len(objects) != 1 {
@@ -147,9 +166,9 @@ func TestRowsToObjects(t *testing.T) {
 			t.Fatalf("failed to query: %v", err)
 		}
 
-		objects, err := RowsToObjects(rows)
+		objects, err := scanObjects(rows)
 		if err != nil {
-			t.Fatalf("RowsToObjects failed: %v", err)
+			t.Fatalf("failed to scan the rows: %v", err)
 		}
 
 		if len(objects) != 3 {
@@ -758,9 +777,9 @@ func TestConvertValueRoundTrip(t *testing.T) {
 			t.Fatalf("failed to query: %v", err)
 		}
 
-		objects, err := RowsToObjects(rows)
+		objects, err := scanObjects(rows)
 		if err != nil {
-			t.Fatalf("RowsToObjects failed: %v", err)
+			t.Fatalf("failed to scan the rows: %v", err)
 		}
 
 		if len(objects) != 1 {
@@ -781,7 +800,7 @@ func TestConvertValueRoundTrip(t *testing.T) {
 	})
 }
 
-func BenchmarkRowsToObjects(b *testing.B) {
+func BenchmarkScanObject(b *testing.B) {
 	db, err := sql.Open("duckdb", "")
 	if err != nil {
 		b.Fatalf("failed to open database: %v", err)
@@ -815,9 +834,9 @@ func BenchmarkRowsToObjects(b *testing.B) {
 			b.Fatalf("failed to query: %v", err)
 		}
 
-		_, err = RowsToObjects(rows)
+		_, err = scanObjects(rows)
 		if err != nil {
-			b.Fatalf("RowsToObjects failed: %v", err)
+			b.Fatalf("failed to scan the rows: %v", err)
 		}
 	}
 }